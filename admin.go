@@ -0,0 +1,214 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// adminMiddleware guards admin endpoints with the ADMIN_TOKEN bearer token.
+// Admin endpoints are disabled entirely when no token is configured.
+func adminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.AdminToken == "" {
+			writeError(w, http.StatusForbidden, codeAdminDisabled, "Admin endpoints are disabled")
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.AdminToken)) != 1 {
+			ErrorLogger.Printf("Rejected admin request to %s: invalid token", r.URL.Path)
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "Unauthorized")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Token DELETE /student/v1/students requires in its confirm parameter
+const deleteAllConfirmation = "DELETE_ALL"
+
+// DELETE /student/v1/students?confirm=DELETE_ALL - Soft-delete every student,
+// or remove them outright with hard=true. Admin only.
+func deleteAllStudents(w http.ResponseWriter, r *http.Request) {
+	confirm, err := singleValue(r.URL.Query(), "confirm")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	if confirm != deleteAllConfirmation {
+		writeError(w, http.StatusBadRequest, codeConfirmationRequired, "Deleting all students requires confirm="+deleteAllConfirmation)
+		return
+	}
+	hard, err := parseBool(r.URL.Query(), "hard")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	var deleted []Student
+	store.Exclusive(func(tx storeTx) error {
+		deletedAt := timestamp()
+		tx.Range(func(student Student) bool {
+			if hard {
+				tx.Delete(student.EnrollmentNumber)
+				deleted = append(deleted, student)
+			} else if !student.IsDeleted {
+				student.IsDeleted = true
+				student.DeletedAt = &deletedAt
+				student.UpdatedAt = deletedAt
+				student.DeletedBy = r.Header.Get("X-Actor")
+				tx.Put(student)
+				deleted = append(deleted, student)
+			}
+			return true
+		})
+		return nil
+	})
+
+	for _, student := range deleted {
+		notifyWebhook(eventStudentDeleted, student)
+	}
+
+	ErrorLogger.Printf("DELETE ALL: removed %d students (hard=%t), actor %q from %s", len(deleted), hard, r.Header.Get("X-Actor"), r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]int{"deleted": len(deleted)})
+}
+
+// backupRecord is a student as stored, including its soft-delete state
+type backupRecord struct {
+	Student
+	Deleted bool `json:"deleted"`
+}
+
+// MarshalJSON adds deleted to the student's fields. Without it the embedded
+// Student.MarshalJSON would be promoted and encode the record alone, leaving
+// the flag out.
+func (b backupRecord) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		studentJSON
+		Deleted bool `json:"deleted"`
+	}{b.Student.encoded(), b.Deleted})
+}
+
+// UnmarshalJSON reads deleted alongside the student's fields, which the
+// promoted Student.UnmarshalJSON would otherwise decode alone
+func (b *backupRecord) UnmarshalJSON(data []byte) error {
+	var flag struct {
+		Deleted bool `json:"deleted"`
+	}
+	if err := json.Unmarshal(data, &flag); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &b.Student); err != nil {
+		return err
+	}
+	b.Deleted = flag.Deleted
+	return nil
+}
+
+// backup is a point-in-time snapshot of the whole store
+type backup struct {
+	CreatedAt    time.Time      `json:"created_at"`
+	Count        int            `json:"count"`
+	LastSequence uint64         `json:"last_sequence"`
+	Students     []backupRecord `json:"students"`
+}
+
+// GET /admin/backup - Dump the entire store, including soft-deleted records
+func backupStore(w http.ResponseWriter, r *http.Request) {
+	var records []backupRecord
+	ok := scanStudents(r, func(student Student) {
+		records = append(records, backupRecord{Student: student, Deleted: student.IsDeleted})
+	})
+	if !ok {
+		return
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].EnrollmentNumber < records[j].EnrollmentNumber
+	})
+
+	InfoLogger.Printf("Created backup of %d students", len(records))
+	writeJSON(w, http.StatusOK, backup{
+		CreatedAt:    timestamp(),
+		Count:        len(records),
+		LastSequence: enrollmentSeq.Load(),
+		Students:     records,
+	})
+}
+
+// POST /admin/restore - Replace the entire store from a backup. Records are
+// held to the same checks as a write, and any failure refuses the whole
+// restore with 422.
+func restoreStore(w http.ResponseWriter, r *http.Request) {
+	var dump backup
+	err := json.NewDecoder(r.Body).Decode(&dump)
+	if err != nil {
+		ErrorLogger.Printf("Failed to decode restore payload: %v", err)
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
+		return
+	}
+
+	restored, err := buildStore(dump.Students)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidBackup, err.Error())
+		return
+	}
+	if verr := validateRecords(restored); verr != nil {
+		writeValidationError(w, r, verr)
+		return
+	}
+
+	// The replacement is fully built before the swap, so readers see either
+	// the old store or the new one, never a mix
+	store.Replace(restored)
+	advanceSequence(dump.LastSequence)
+
+	InfoLogger.Printf("Restored store from backup with %d students", len(restored))
+	writeJSON(w, http.StatusOK, map[string]int{"restored": len(restored)})
+}
+
+// buildStore validates backup records and converts them into store records
+func buildStore(records []backupRecord) ([]Student, error) {
+	seen := make(map[string]bool, len(records))
+	restored := make([]Student, 0, len(records))
+	for i, record := range records {
+		if record.EnrollmentNumber == "" {
+			return nil, fmt.Errorf("record %d: missing enrollment_number", i)
+		}
+		key := storeKey(record.EnrollmentNumber)
+		if seen[key] {
+			return nil, fmt.Errorf("record %d: duplicate enrollment_number %q", i, record.EnrollmentNumber)
+		}
+		seen[key] = true
+
+		student := record.Student
+		student.IsDeleted = record.Deleted
+		restored = append(restored, student)
+	}
+	return restored, nil
+}
+
+// validateRecords runs validateStudent on every record buildStore returned,
+// so a restore can't bring back data the write endpoints would refuse.
+// Fields are prefixed with the record's index, e.g. "[3].age".
+func validateRecords(students []Student) *validationError {
+	var errs []fieldError
+	for i, student := range students {
+		if verr := validateStudent(student); verr != nil {
+			for _, field := range verr.Fields {
+				field.Field = fmt.Sprintf("[%d].%s", i, field.Field)
+				errs = append(errs, field)
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &validationError{Fields: errs}
+}
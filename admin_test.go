@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testAdminToken = "test-admin-token"
+
+// withAdmin enables the admin endpoints for newTestServer
+func withAdmin(c *Config) {
+	c.AdminToken = testAdminToken
+}
+
+var adminHeader = []string{"Authorization", "Bearer " + testAdminToken}
+
+// TestBackupRestoreKeepsDeleted round-trips a store holding a soft-deleted
+// student through /admin/backup and /admin/restore
+func TestBackupRestoreKeepsDeleted(t *testing.T) {
+	srv := newTestServer(t, withAdmin)
+	kept := mustCreate(t, srv, map[string]interface{}{"name": "Ann", "age": 10, "class": "5A"})
+	deleted := mustCreate(t, srv, map[string]interface{}{"name": "Ben", "age": 11, "class": "5A"})
+	if resp, body := doJSON(t, srv, http.MethodDelete, "/student/v1/students/"+deleted, nil); resp.StatusCode >= 300 {
+		t.Fatalf("delete: status %d: %s", resp.StatusCode, body)
+	}
+
+	resp, dump := doJSON(t, srv, http.MethodGet, "/admin/backup", nil, adminHeader...)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("backup: status %d: %s", resp.StatusCode, dump)
+	}
+	var decoded struct {
+		Students []map[string]interface{} `json:"students"`
+	}
+	if err := json.Unmarshal(dump, &decoded); err != nil {
+		t.Fatalf("decoding backup: %v", err)
+	}
+	flags := make(map[string]interface{})
+	for _, record := range decoded.Students {
+		flags[record["enrollment_number"].(string)] = record["deleted"]
+	}
+	if flags[kept] != false || flags[deleted] != true {
+		t.Fatalf("backup deleted flags = %v, want %s false and %s true", flags, kept, deleted)
+	}
+
+	// Restore over a store that has changed since
+	store.Replace(nil)
+	resp, body := doJSON(t, srv, http.MethodPost, "/admin/restore", string(dump), adminHeader...)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("restore: status %d: %s", resp.StatusCode, body)
+	}
+
+	if student, exists := store.Get(deleted); !exists || !student.IsDeleted || student.DeletedAt == nil {
+		t.Errorf("restored %s = %+v, want it soft-deleted", deleted, student)
+	}
+	if resp, _ := doJSON(t, srv, http.MethodGet, "/student/v1/students/"+deleted, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET restored deleted student: status %d, want 404", resp.StatusCode)
+	}
+	if resp, _ := doJSON(t, srv, http.MethodGet, "/student/v1/students/"+kept, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET restored active student: status %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestAdminUIRequiresToken(t *testing.T) {
+	srv := newTestServer(t, func(c *Config) {
+		withAdmin(c)
+		c.AdminUI = true
+	})
+	if resp, _ := doJSON(t, srv, http.MethodGet, "/admin/ui", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET /admin/ui without a token: status %d, want 401", resp.StatusCode)
+	}
+	resp, body := doJSON(t, srv, http.MethodGet, "/admin/ui", nil, adminHeader...)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("GET /admin/ui with the token: status %d, Content-Type %q: %.80s", resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	}
+
+	cfg.AdminUI = false
+	if resp, _ := doJSON(t, srv, http.MethodGet, "/admin/ui", nil, adminHeader...); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /admin/ui with ADMIN_UI off: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestDeleteAllConfirmation(t *testing.T) {
+	srv := newTestServer(t, withAdmin)
+	mustCreate(t, srv, map[string]interface{}{"name": "Ann", "age": 10, "class": "5A"})
+
+	for _, query := range []string{"", "?confirm=yes", "?confirm=x&confirm=DELETE_ALL", "?confirm=DELETE_ALL&confirm=x"} {
+		resp, body := doJSON(t, srv, http.MethodDelete, "/student/v1/students"+query, nil, adminHeader...)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("DELETE%s: status %d: %s, want 400", query, resp.StatusCode, body)
+		}
+	}
+	if got := len(store.Active()); got != 1 {
+		t.Fatalf("%d students after refused deletes, want 1", got)
+	}
+
+	resp, body := doJSON(t, srv, http.MethodDelete, "/student/v1/students?confirm=DELETE_ALL", nil, adminHeader...)
+	if resp.StatusCode != http.StatusOK || len(store.Active()) != 0 {
+		t.Errorf("DELETE ?confirm=DELETE_ALL: status %d: %s, want every student deleted", resp.StatusCode, body)
+	}
+}
+
+// TestRestoreValidatesRecords restores a backup whose records the write
+// endpoints would refuse and checks the store is left alone
+func TestRestoreValidatesRecords(t *testing.T) {
+	srv := newTestServer(t, func(c *Config) {
+		withAdmin(c)
+		c.AllowedClasses = []string{"5A"}
+	})
+	kept := mustCreate(t, srv, map[string]interface{}{"name": "Ann", "age": 10, "class": "5A"})
+
+	dump := `{"students":[
+		{"enrollment_number":"R1","name":"","age":10,"class":"5A"},
+		{"enrollment_number":"R2","name":"Ben","age":500,"class":"5A"},
+		{"enrollment_number":"R3","name":"Cat","age":10,"class":"9Z","deleted":true}
+	]}`
+	for _, path := range []string{"/admin/restore", "/admin/import-archive?mode=replace"} {
+		body := dump
+		if path != "/admin/restore" {
+			body = `{"format":"` + archiveFormat + `","schema_version":1,"counts":{"total":3,"active":2,"deleted":1},` + dump[1:]
+		}
+		resp, reply := doJSON(t, srv, http.MethodPost, path, body, adminHeader...)
+		var decoded struct {
+			Code   string       `json:"code"`
+			Fields []fieldError `json:"fields"`
+		}
+		json.Unmarshal(reply, &decoded)
+		got := make(map[string]bool)
+		for _, field := range decoded.Fields {
+			got[field.Field] = true
+		}
+		if resp.StatusCode != http.StatusUnprocessableEntity || !got["[0].name"] || !got["[1].age"] || !got["[2].class"] {
+			t.Errorf("%s: status %d: %s, want 422 naming [0].name, [1].age and [2].class", path, resp.StatusCode, reply)
+		}
+	}
+
+	if _, exists := store.Get(kept); !exists || len(store.Active()) != 1 {
+		t.Error("a refused restore replaced the store")
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Archives identify themselves by archiveFormat and carry the
+// archiveSchemaVersion they were written with. Bump the version whenever the
+// record layout changes incompatibly, and teach importArchive to upgrade the
+// versions before it.
+const (
+	archiveFormat        = "student-api-archive"
+	archiveSchemaVersion = 1
+)
+
+// Ways POST /admin/import-archive combines an archive with the store
+const (
+	archiveModeMerge   = "merge"
+	archiveModeReplace = "replace"
+)
+
+// archiveCounts summarizes an archive's records, so an import can tell a
+// truncated archive from a complete one
+type archiveCounts struct {
+	Total   int `json:"total"`
+	Active  int `json:"active"`
+	Deleted int `json:"deleted"`
+}
+
+// archive is the versioned document moved between instances by
+// /admin/export-archive and /admin/import-archive. Unlike a backup it says
+// which format and schema version its records follow.
+type archive struct {
+	Format        string         `json:"format"`
+	SchemaVersion int            `json:"schema_version"`
+	APIVersion    string         `json:"api_version"`
+	ExportedAt    time.Time      `json:"exported_at"`
+	LastSequence  uint64         `json:"last_sequence"`
+	Counts        archiveCounts  `json:"counts"`
+	Students      []backupRecord `json:"students"`
+}
+
+// countRecords tallies records for archiveCounts
+func countRecords(records []backupRecord) archiveCounts {
+	counts := archiveCounts{Total: len(records)}
+	for _, record := range records {
+		if record.Deleted {
+			counts.Deleted++
+		} else {
+			counts.Active++
+		}
+	}
+	return counts
+}
+
+// GET /admin/export-archive - Bundle every record, soft-deleted ones
+// included, into a versioned archive. Admin only.
+func exportArchive(w http.ResponseWriter, r *http.Request) {
+	var records []backupRecord
+	ok := scanStudents(r, func(student Student) {
+		records = append(records, backupRecord{Student: student, Deleted: student.IsDeleted})
+	})
+	if !ok {
+		return
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].EnrollmentNumber < records[j].EnrollmentNumber
+	})
+	if records == nil {
+		records = []backupRecord{}
+	}
+
+	InfoLogger.Printf("Exported archive of %d students", len(records))
+	writeJSON(w, http.StatusOK, archive{
+		Format:        archiveFormat,
+		SchemaVersion: archiveSchemaVersion,
+		APIVersion:    apiVersion,
+		ExportedAt:    timestamp(),
+		LastSequence:  enrollmentSeq.Load(),
+		Counts:        countRecords(records),
+		Students:      records,
+	})
+}
+
+// POST /admin/import-archive?mode=merge|replace - Load an archive from
+// export-archive. replace swaps the whole store for the archive's records,
+// like /admin/restore; merge, the default, keeps the store and overwrites
+// just the records the archive holds. Archives of another format, from a
+// newer schema version than this server knows, or whose counts don't match
+// their records are rejected before anything changes. Admin only.
+func importArchive(w http.ResponseWriter, r *http.Request) {
+	mode, err := singleValue(r.URL.Query(), "mode")
+	if err == nil && mode == "" {
+		mode = archiveModeMerge
+	} else if err == nil && mode != archiveModeMerge && mode != archiveModeReplace {
+		err = fmt.Errorf("invalid mode: must be %s or %s", archiveModeMerge, archiveModeReplace)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	var doc archive
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+		ErrorLogger.Printf("Failed to decode archive: %v", err)
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
+		return
+	}
+	switch {
+	case doc.Format != archiveFormat:
+		writeError(w, http.StatusBadRequest, codeInvalidArchive, fmt.Sprintf("Not a %s document", archiveFormat))
+		return
+	case doc.SchemaVersion > archiveSchemaVersion:
+		writeError(w, http.StatusUnprocessableEntity, codeUnsupportedArchive,
+			fmt.Sprintf("Archive schema version %d is newer than the %d this server supports; upgrade the server first", doc.SchemaVersion, archiveSchemaVersion))
+		return
+	case doc.SchemaVersion < 1:
+		writeError(w, http.StatusBadRequest, codeInvalidArchive, "Missing or invalid schema_version")
+		return
+	case countRecords(doc.Students) != doc.Counts:
+		writeError(w, http.StatusBadRequest, codeInvalidArchive, "Record counts do not match the archive's counts; it may be truncated")
+		return
+	}
+
+	records, err := buildStore(doc.Students)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidArchive, err.Error())
+		return
+	}
+	if verr := validateRecords(records); verr != nil {
+		writeValidationError(w, r, verr)
+		return
+	}
+
+	result := map[string]interface{}{"mode": mode, "imported": len(records)}
+	if mode == archiveModeReplace {
+		store.Replace(records)
+	} else {
+		created, replaced := 0, 0
+		store.Exclusive(func(tx storeTx) error {
+			for _, student := range records {
+				if _, exists := tx.Get(student.EnrollmentNumber); exists {
+					replaced++
+				} else {
+					created++
+				}
+				tx.Put(student)
+			}
+			return nil
+		})
+		result["created"], result["replaced"] = created, replaced
+	}
+	advanceSequence(doc.LastSequence)
+
+	InfoLogger.Printf("Imported archive (schema version %d, exported %s) with %d students, mode %s", doc.SchemaVersion, doc.ExportedAt.Format(time.RFC3339), len(records), mode)
+	writeJSON(w, http.StatusOK, result)
+}
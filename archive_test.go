@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// TestArchiveRoundTrip exports a store holding a soft-deleted student and
+// imports it back in both modes
+func TestArchiveRoundTrip(t *testing.T) {
+	for _, mode := range []string{archiveModeMerge, archiveModeReplace} {
+		t.Run(mode, func(t *testing.T) {
+			srv := newTestServer(t, withAdmin)
+			kept := mustCreate(t, srv, map[string]interface{}{"name": "Ann", "age": 10, "class": "5A"})
+			deleted := mustCreate(t, srv, map[string]interface{}{"name": "Ben", "age": 11, "class": "5A"})
+			if resp, body := doJSON(t, srv, http.MethodDelete, "/student/v1/students/"+deleted, nil); resp.StatusCode >= 300 {
+				t.Fatalf("delete: status %d: %s", resp.StatusCode, body)
+			}
+
+			resp, exported := doJSON(t, srv, http.MethodGet, "/admin/export-archive", nil, adminHeader...)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("export: status %d: %s", resp.StatusCode, exported)
+			}
+
+			store.Replace(nil)
+			resp, body := doJSON(t, srv, http.MethodPost, "/admin/import-archive?mode="+mode, string(exported), adminHeader...)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("import: status %d: %s", resp.StatusCode, body)
+			}
+
+			if student, exists := store.Get(deleted); !exists || !student.IsDeleted {
+				t.Errorf("imported %s = %+v, want it soft-deleted", deleted, student)
+			}
+			if student, exists := store.Get(kept); !exists || student.IsDeleted {
+				t.Errorf("imported %s = %+v, want it active", kept, student)
+			}
+		})
+	}
+}
+
+func TestImportArchiveRejects(t *testing.T) {
+	srv := newTestServer(t, withAdmin)
+	mustCreate(t, srv, map[string]interface{}{"name": "Ann", "age": 10, "class": "5A"})
+	_, exported := doJSON(t, srv, http.MethodGet, "/admin/export-archive", nil, adminHeader...)
+
+	for _, tc := range []struct {
+		name   string
+		edit   func(doc map[string]interface{})
+		status int
+		code   string
+	}{
+		{"newer schema", func(doc map[string]interface{}) { doc["schema_version"] = archiveSchemaVersion + 1 }, http.StatusUnprocessableEntity, codeUnsupportedArchive},
+		{"missing schema", func(doc map[string]interface{}) { delete(doc, "schema_version") }, http.StatusBadRequest, codeInvalidArchive},
+		{"other format", func(doc map[string]interface{}) { doc["format"] = "something-else" }, http.StatusBadRequest, codeInvalidArchive},
+		{"truncated", func(doc map[string]interface{}) { doc["students"] = []interface{}{} }, http.StatusBadRequest, codeInvalidArchive},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var doc map[string]interface{}
+			json.Unmarshal(exported, &doc)
+			tc.edit(doc)
+			resp, body := doJSON(t, srv, http.MethodPost, "/admin/import-archive", doc, adminHeader...)
+			var reply struct {
+				Code string `json:"code"`
+			}
+			json.Unmarshal(body, &reply)
+			if resp.StatusCode != tc.status || reply.Code != tc.code {
+				t.Errorf("status %d code %q, want %d %q: %s", resp.StatusCode, reply.Code, tc.status, tc.code, body)
+			}
+		})
+	}
+}
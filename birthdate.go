@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Layout of date_of_birth, a calendar date without a time zone
+const dateOfBirthLayout = "2006-01-02"
+
+// parseDateOfBirth parses a date_of_birth value
+func parseDateOfBirth(value string) (time.Time, error) {
+	return time.Parse(dateOfBirthLayout, value)
+}
+
+// ageOn returns how many whole years old someone born on dob is at t
+func ageOn(dob, t time.Time) int {
+	age := t.Year() - dob.Year()
+	if t.Month() < dob.Month() || (t.Month() == dob.Month() && t.Day() < dob.Day()) {
+		age--
+	}
+	return age
+}
+
+// currentAge is the student's age today: derived from DateOfBirth when set,
+// so it never goes stale, otherwise the stored Age
+func (s Student) currentAge() int {
+	if s.DateOfBirth == "" {
+		return s.Age
+	}
+	dob, err := parseDateOfBirth(s.DateOfBirth)
+	if err != nil {
+		return s.Age
+	}
+	return ageOn(dob, timestamp())
+}
+
+// studentJSON has Student's fields without its methods, so MarshalJSON can
+// encode it without recursing
+type studentJSON Student
+
+// MarshalJSON writes age as currentAge, so every response, backup and
+// webhook shows the age as of the moment it was produced, and subjects as []
+// rather than null, see withSubjects
+func (s Student) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.encoded())
+}
+
+// encoded is s as MarshalJSON writes it, for types that embed Student and
+// add fields of their own
+func (s Student) encoded() studentJSON {
+	s = withSubjects(s)
+	s.Age = s.currentAge()
+	return studentJSON(s)
+}
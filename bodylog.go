@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// cappedBuffer keeps the first max bytes written to it and drops the rest,
+// remembering that it did
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	if room := c.max - c.buf.Len(); len(p) > room {
+		c.buf.Write(p[:max(room, 0)])
+		c.truncated = true
+	} else {
+		c.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// render returns what was kept, with redact's matches masked, marked when
+// cut short
+func (c *cappedBuffer) render(redact *regexp.Regexp) string {
+	s := c.buf.String()
+	if redact != nil {
+		s = redact.ReplaceAllString(s, `${1}"[REDACTED]"`)
+	}
+	if c.truncated {
+		s += "...(truncated)"
+	}
+	return s
+}
+
+// bodyRecorder copies what a handler writes into a cappedBuffer
+type bodyRecorder struct {
+	http.ResponseWriter
+	body *cappedBuffer
+}
+
+func (b *bodyRecorder) Write(p []byte) (int, error) {
+	b.body.Write(p)
+	return b.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (b *bodyRecorder) Unwrap() http.ResponseWriter {
+	return b.ResponseWriter
+}
+
+// redactPattern matches a JSON member with one of the given names, along
+// with its string or scalar value, or returns nil when there are none. It
+// works on raw text rather than decoded JSON, so truncated and malformed
+// bodies are redacted too, including a string value cut off mid-way; nested
+// objects and arrays under a listed name are left as is.
+func redactPattern(fields []string) *regexp.Regexp {
+	if len(fields) == 0 {
+		return nil
+	}
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = regexp.QuoteMeta(field)
+	}
+	return regexp.MustCompile(`(?i)("(?:` + strings.Join(names, "|") + `)"\s*:\s*)(?:"(?:[^"\\]|\\.)*(?:"|\\?$)|[^\s,}\]]+)`)
+}
+
+// bodyLogMiddleware logs each request's and response's body to DebugLogger,
+// each cut to LOG_BODY_MAX_BYTES and with LOG_REDACT_FIELDS masked. The
+// request body is teed as the handler reads it, so only what was actually
+// read is logged and nothing is buffered ahead of the handler.
+func bodyLogMiddleware(next http.Handler) http.Handler {
+	redact := redactPattern(cfg.LogRedactFields)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := &cappedBuffer{max: cfg.LogBodyMaxBytes}
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(r.Body, request), r.Body}
+		rec := &bodyRecorder{ResponseWriter: w, body: &cappedBuffer{max: cfg.LogBodyMaxBytes}}
+
+		next.ServeHTTP(rec, r)
+
+		DebugLogger.Printf("%s %s request body: %s", r.Method, r.URL.Path, request.render(redact))
+		DebugLogger.Printf("%s %s response body: %s", r.Method, r.URL.Path, rec.body.render(redact))
+	})
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// Bulk endpoint modes, chosen with ?mode=
+//   - atomic (default): every item succeeds or nothing is written, and the
+//     first failure is returned as an ordinary error response
+//   - partial: each item is applied on its own, and the response reports
+//     per item what happened so clients can retry just the failures
+const (
+	bulkModeAtomic  = "atomic"
+	bulkModePartial = "partial"
+)
+
+// Per-item outcomes reported by bulk endpoints. For batch-get, deleted means
+// soft-deleted, as opposed to not_found for an id that never existed.
+// assign-subject reports skipped for students already taking the subject.
+const (
+	bulkStatusAdded    = "added"
+	bulkStatusCreated  = "created"
+	bulkStatusDeleted  = "deleted"
+	bulkStatusError    = "error"
+	bulkStatusFound    = "found"
+	bulkStatusNotFound = "not_found"
+	bulkStatusSkipped  = "skipped"
+)
+
+// bulkItemResult is the outcome of one item of a bulk request
+type bulkItemResult struct {
+	Index            int    `json:"index"`
+	Status           string `json:"status"`
+	EnrollmentNumber string `json:"enrollment_number,omitempty"`
+	Code             string `json:"code,omitempty"`
+	Message          string `json:"message,omitempty"`
+}
+
+// bulkItemError is the failure of one item in an atomic bulk request
+type bulkItemError struct {
+	index  int
+	status int
+	code   string
+	msg    string
+}
+
+func (e *bulkItemError) Error() string {
+	return fmt.Sprintf("item %d: %s", e.index, e.msg)
+}
+
+func parseBulkMode(r *http.Request) (string, error) {
+	mode, err := singleValue(r.URL.Query(), "mode")
+	switch {
+	case err != nil:
+		return "", err
+	case mode == "":
+		return bulkModeAtomic, nil
+	case mode == bulkModeAtomic || mode == bulkModePartial:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid mode: must be %s or %s", bulkModeAtomic, bulkModePartial)
+	}
+}
+
+// writeBulkResults answers a bulk request, failing atomic ones as a whole
+// with the item error that aborted them
+func writeBulkResults(w http.ResponseWriter, results []bulkItemResult, err error) {
+	var ierr *bulkItemError
+	if errors.As(err, &ierr) {
+		writeError(w, ierr.status, ierr.code, ierr.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// POST /student/v1/students/bulk - Create every student in a JSON array.
+// Items are validated like single creates and see each other for uniqueness.
+func bulkCreateStudents(w http.ResponseWriter, r *http.Request) {
+	mode, err := parseBulkMode(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	var rows []Student
+	err = json.NewDecoder(r.Body).Decode(&rows)
+	if err != nil {
+		ErrorLogger.Printf("Failed to decode request body: %v", err)
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
+		return
+	}
+
+	var results []bulkItemResult
+	var created []Student
+	err = store.Exclusive(func(tx storeTx) error {
+		var err error
+		results, created, err = createRows(tx, rows, 0, mode)
+		return err
+	})
+	if err != nil {
+		created = nil
+	}
+
+	for _, student := range created {
+		notifyWebhook(eventStudentCreated, student)
+	}
+	InfoLogger.Printf("Bulk created %d of %d students (mode %s)", len(created), len(rows), mode)
+	writeBulkResults(w, results, err)
+}
+
+// createRows creates rows inside tx the way bulk creates do: each is
+// validated like a single create and sees the rows before it for uniqueness.
+// Results are indexed from first. In atomic mode the first failure is
+// returned, and the caller's transaction must roll back what came before.
+func createRows(tx storeTx, rows []Student, first int, mode string) ([]bulkItemResult, []Student, error) {
+	results := make([]bulkItemResult, len(rows))
+	var created []Student
+	for i, student := range rows {
+		results[i] = bulkItemResult{Index: first + i, Status: bulkStatusCreated}
+		student = withoutDeleteAudit(inferClass(student))
+
+		var failure *bulkItemError
+		if verr := validateNewStudent(student); verr != nil {
+			failure = &bulkItemError{index: first + i, status: http.StatusUnprocessableEntity, code: codeValidationFailed, msg: verr.Error()}
+		} else {
+			var err error
+			if student.EnrollmentNumber == "" {
+				student.EnrollmentNumber, err = allocateEnrollmentNumber(func(id string) bool {
+					_, exists := tx.Get(id)
+					return exists
+				})
+			}
+			switch {
+			case err != nil:
+				failure = &bulkItemError{index: first + i, status: http.StatusInternalServerError, code: codeInternal, msg: err.Error()}
+			case checkUnique(tx, student, "") != nil:
+				failure = &bulkItemError{index: first + i, status: http.StatusConflict, code: codeDuplicateStudent, msg: "student already exists"}
+			default:
+				if err := checkCapacity(tx, student, ""); err != nil {
+					failure = &bulkItemError{index: first + i, status: http.StatusConflict, code: codeClassFull, msg: err.Error()}
+				}
+			}
+		}
+
+		if failure != nil {
+			if mode == bulkModeAtomic {
+				return results, nil, failure
+			}
+			results[i] = bulkItemResult{Index: first + i, Status: bulkStatusError, Code: failure.code, Message: failure.msg}
+			continue
+		}
+
+		student.CreatedAt = timestamp()
+		student.UpdatedAt = student.CreatedAt
+		tx.Put(student)
+		created = append(created, student)
+		results[i].EnrollmentNumber = student.EnrollmentNumber
+	}
+	return results, created, nil
+}
+
+// POST /student/v1/students/batch-delete - Soft-delete the students listed
+// in {"ids": [...]}, like DELETE on each
+func batchDeleteStudents(w http.ResponseWriter, r *http.Request) {
+	mode, err := parseBulkMode(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ErrorLogger.Printf("Failed to decode request body: %v", err)
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
+		return
+	}
+
+	results := make([]bulkItemResult, len(req.IDs))
+	var deleted []Student
+	err = store.Exclusive(func(tx storeTx) error {
+		deletedAt := timestamp()
+		for i, id := range req.IDs {
+			student, exists := tx.Get(id)
+			if !exists || student.IsDeleted {
+				if mode == bulkModeAtomic {
+					return &bulkItemError{index: i, status: http.StatusNotFound, code: codeStudentNotFound, msg: "student " + id + " not found"}
+				}
+				results[i] = bulkItemResult{Index: i, Status: bulkStatusError, EnrollmentNumber: id, Code: codeStudentNotFound, Message: "student not found"}
+				continue
+			}
+
+			student.IsDeleted = true
+			student.DeletedAt = &deletedAt
+			student.UpdatedAt = deletedAt
+			student.DeletedBy = r.Header.Get("X-Actor")
+			tx.Put(student)
+			deleted = append(deleted, student)
+			results[i] = bulkItemResult{Index: i, Status: bulkStatusDeleted, EnrollmentNumber: student.EnrollmentNumber}
+		}
+		return nil
+	})
+	if err != nil {
+		deleted = nil
+	}
+
+	for _, student := range deleted {
+		notifyWebhook(eventStudentDeleted, student)
+	}
+	InfoLogger.Printf("Batch deleted %d of %d students (mode %s)", len(deleted), len(req.IDs), mode)
+	writeBulkResults(w, results, err)
+}
+
+// batchGetResult is the outcome of looking up one requested id
+type batchGetResult struct {
+	EnrollmentNumber string   `json:"enrollment_number"`
+	Status           string   `json:"status"`
+	Student          *Student `json:"student,omitempty"`
+}
+
+// POST /student/v1/students/batch-get - Look up the students listed in
+// {"ids": [...]} from one consistent snapshot. Each id is answered, in request
+// order, as found (with the record), deleted or not_found, so clients can tell
+// deletions from ids that never existed. ?format=bare instead returns just
+// the found records as a plain array.
+func batchGetStudents(w http.ResponseWriter, r *http.Request) {
+	format, err := singleValue(r.URL.Query(), "format")
+	if err == nil && format != "" && format != "bare" {
+		err = errors.New("invalid format: must be bare")
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ErrorLogger.Printf("Failed to decode request body: %v", err)
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
+		return
+	}
+
+	results := make([]batchGetResult, len(req.IDs))
+	found := []Student{}
+	store.View(func(tx storeTx) {
+		for i, id := range req.IDs {
+			results[i] = batchGetResult{EnrollmentNumber: id, Status: bulkStatusNotFound}
+			student, exists := tx.Get(id)
+			switch {
+			case !exists:
+			case student.IsDeleted:
+				results[i].Status = bulkStatusDeleted
+			default:
+				results[i].Status = bulkStatusFound
+				results[i].Student = &student
+				found = append(found, student)
+			}
+		}
+	})
+
+	InfoLogger.Printf("Batch retrieved %d of %d students", len(found), len(req.IDs))
+	if format == "bare" {
+		writeJSON(w, http.StatusOK, found)
+		return
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// POST /student/v1/students/assign-subject - Add {"subject": "..."} to every
+// active student listed in {"ids": [...]}, like POST on each one's subjects.
+// Students already taking it, compared case-insensitively, are skipped, and
+// missing ids are reported with a 404 code rather than failing the batch.
+func assignSubject(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		IDs     []string `json:"ids"`
+		Subject string   `json:"subject"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ErrorLogger.Printf("Failed to decode request body: %v", err)
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
+		return
+	}
+	if errs := checkSubject(nil, "subject", req.Subject); len(errs) > 0 {
+		writeValidationError(w, r, &validationError{Fields: errs})
+		return
+	}
+
+	results := make([]bulkItemResult, len(req.IDs))
+	var updated []Student
+	store.Exclusive(func(tx storeTx) error {
+		updatedAt := timestamp()
+		for i, id := range req.IDs {
+			results[i] = bulkItemResult{Index: i, EnrollmentNumber: id}
+			student, exists := tx.Get(id)
+			switch {
+			case !exists || student.IsDeleted:
+				results[i].Status = bulkStatusNotFound
+				results[i].Code = codeStudentNotFound
+				results[i].Message = "student not found"
+			case hasSubject(student.Subjects, req.Subject):
+				results[i].Status = bulkStatusSkipped
+				results[i].EnrollmentNumber = student.EnrollmentNumber
+			default:
+				student.Subjects = append(slices.Clip(student.Subjects), req.Subject)
+				if errs := checkRules(nil, student); len(errs) > 0 {
+					results[i].Status = bulkStatusError
+					results[i].Code = codeValidationFailed
+					results[i].Message = (&validationError{Fields: errs}).Error()
+					continue
+				}
+				student.UpdatedAt = updatedAt
+				tx.Put(student)
+				updated = append(updated, student)
+				results[i].Status = bulkStatusAdded
+				results[i].EnrollmentNumber = student.EnrollmentNumber
+			}
+		}
+		return nil
+	})
+
+	for _, student := range updated {
+		notifyWebhook(eventStudentUpdated, student)
+	}
+	InfoLogger.Printf("Assigned subject %s to %d of %d students", req.Subject, len(updated), len(req.IDs))
+	writeJSON(w, http.StatusOK, results)
+}
+
+// POST /student/v1/students/assign-ids - Number a roster of students that
+// have no enrollment numbers yet, as ENROLLMENT_MODE would on create. By
+// default this is only a preview: nothing is stored and, in sequence mode, no
+// numbers are consumed, so committing later may assign different ones. With
+// ?commit=true the students are created all-or-nothing, like an atomic bulk
+// create, and returned as stored.
+func assignEnrollmentNumbers(w http.ResponseWriter, r *http.Request) {
+	commit, err := parseBool(r.URL.Query(), "commit")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	var rows []Student
+	err = json.NewDecoder(r.Body).Decode(&rows)
+	if err != nil {
+		ErrorLogger.Printf("Failed to decode request body: %v", err)
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
+		return
+	}
+
+	var fields []fieldError
+	for i := range rows {
+		rows[i] = withoutDeleteAudit(inferClass(rows[i]))
+		if rows[i].EnrollmentNumber != "" {
+			fields = append(fields, newFieldError(fmt.Sprintf("[%d].enrollment_number", i), fieldMustBeEmpty))
+		}
+		if verr := validateNewStudent(rows[i]); verr != nil {
+			for _, f := range verr.Fields {
+				f.Field = fmt.Sprintf("[%d].%s", i, f.Field)
+				fields = append(fields, f)
+			}
+		}
+	}
+	if len(fields) > 0 {
+		writeValidationError(w, r, &validationError{Fields: fields})
+		return
+	}
+
+	if !commit {
+		var ids []string
+		store.View(func(tx storeTx) {
+			ids, err = previewEnrollmentNumbers(len(rows), func(id string) bool {
+				_, exists := tx.Get(id)
+				return exists
+			})
+		})
+		if err != nil {
+			ErrorLogger.Printf("Failed to preview enrollment numbers: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Could not allocate an enrollment number")
+			return
+		}
+		createdAt := timestamp()
+		for i := range rows {
+			rows[i].EnrollmentNumber = ids[i]
+			rows[i].CreatedAt = createdAt
+			rows[i].UpdatedAt = createdAt
+		}
+		InfoLogger.Printf("Previewed enrollment numbers for %d students", len(rows))
+		writeJSON(w, http.StatusOK, rows)
+		return
+	}
+
+	var full *classFullError
+	err = store.Exclusive(func(tx storeTx) error {
+		createdAt := timestamp()
+		for i := range rows {
+			id, err := allocateEnrollmentNumber(func(id string) bool {
+				_, exists := tx.Get(id)
+				return exists
+			})
+			if err != nil {
+				return err
+			}
+			rows[i].EnrollmentNumber = id
+			rows[i].CreatedAt = createdAt
+			rows[i].UpdatedAt = createdAt
+			if err := checkUnique(tx, rows[i], ""); err != nil {
+				return &bulkItemError{index: i, status: http.StatusConflict, code: codeDuplicateStudent, msg: "student already exists"}
+			}
+			if err := checkCapacity(tx, rows[i], ""); err != nil {
+				return err
+			}
+			tx.Put(rows[i])
+		}
+		return nil
+	})
+	var ierr *bulkItemError
+	switch {
+	case errors.As(err, &ierr):
+		writeError(w, ierr.status, ierr.code, ierr.Error())
+		return
+	case errors.As(err, &full):
+		writeClassFull(w, full)
+		return
+	case err != nil:
+		ErrorLogger.Printf("Failed to assign enrollment numbers: %v", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Could not allocate an enrollment number")
+		return
+	}
+
+	for _, student := range rows {
+		notifyWebhook(eventStudentCreated, student)
+	}
+	InfoLogger.Printf("Assigned enrollment numbers to and created %d students", len(rows))
+	writeJSON(w, http.StatusOK, rows)
+}
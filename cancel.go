@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// How many records a scan visits between checks for a disconnected client
+const cancelCheckInterval = 1024
+
+// requestCancelled reports whether the client has gone away or the request
+// deadline passed, logging it so abandoned work shows up in the logs
+func requestCancelled(r *http.Request) bool {
+	if err := requestErr(r); err != nil {
+		InfoLogger.Printf("Aborting %s %s: %v", r.Method, r.URL.Path, err)
+		return true
+	}
+	return false
+}
+
+// requestErr returns why the request's context is done, or nil. The deadline
+// is compared directly rather than waiting on the context's timer, which may
+// not get to run while a long scan keeps the CPU busy.
+func requestErr(r *http.Request) error {
+	ctx := r.Context()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// scanStudents ranges over the store like store.Range, but stops early once
+// the request is cancelled. It returns false when the scan was aborted, in
+// which case the handler should return without writing a response.
+func scanStudents(r *http.Request, fn func(Student)) bool {
+	if requestCancelled(r) {
+		return false
+	}
+
+	visited := 0
+	cancelled := false
+	store.Range(func(student Student) bool {
+		visited++
+		if visited%cancelCheckInterval == 0 && requestErr(r) != nil {
+			cancelled = true
+			return false
+		}
+		fn(student)
+		return true
+	})
+
+	if cancelled {
+		requestCancelled(r)
+		return false
+	}
+	return true
+}
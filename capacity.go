@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// classFullError is a write that would put a student into a class already
+// at its CLASS_CAPACITY
+type classFullError struct {
+	Class    string
+	Capacity int
+}
+
+func (e *classFullError) Error() string {
+	return fmt.Sprintf("class %s is full (capacity %d)", e.Class, e.Capacity)
+}
+
+// parseClassCapacity parses a CLASS_CAPACITY value such as "10A:30,10B:25"
+func parseClassCapacity(raw string) (map[string]int, error) {
+	capacities := make(map[string]int)
+	for _, entry := range strings.Split(raw, ",") {
+		class, limit, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		class = strings.TrimSpace(class)
+		n, err := strconv.Atoi(strings.TrimSpace(limit))
+		if !ok || class == "" || err != nil || n < 0 {
+			return nil, fmt.Errorf("entry %q must look like 10A:30", entry)
+		}
+		capacities[class] = n
+	}
+	return capacities, nil
+}
+
+// classCapacity returns the capacity configured for class, matched
+// case-insensitively like the class filters
+func classCapacity(class string) (int, bool) {
+	for name, capacity := range cfg.ClassCapacity {
+		if strings.EqualFold(name, class) {
+			return capacity, true
+		}
+	}
+	return 0, false
+}
+
+// checkCapacity reports whether storing student keeps its class within
+// CLASS_CAPACITY. excludeID is the record being written, or empty on create;
+// a student already active in the class never counts against itself, so
+// edits to members of a class that has been shrunk below its size still go
+// through. Counting needs every record, so the transaction must come from
+// writeUnique or Exclusive.
+func checkCapacity(tx storeTx, student Student, excludeID string) error {
+	capacity, limited := classCapacity(student.Class)
+	if !limited {
+		return nil
+	}
+	if existing, exists := tx.Get(excludeID); excludeID != "" && exists &&
+		!existing.IsDeleted && strings.EqualFold(existing.Class, student.Class) {
+		return nil
+	}
+
+	members := 0
+	tx.Range(func(other Student) bool {
+		if !other.IsDeleted && strings.EqualFold(other.Class, student.Class) &&
+			storeKey(other.EnrollmentNumber) != storeKey(excludeID) {
+			members++
+		}
+		return true
+	})
+	if members >= capacity {
+		return &classFullError{Class: student.Class, Capacity: capacity}
+	}
+	return nil
+}
+
+// checkClassSize reports whether class is within its capacity once a
+// transaction has already written the students moving in. Multi-record writes
+// use it, so members moving between classes in the same write are counted
+// where they end up.
+func checkClassSize(tx storeTx, class string) error {
+	capacity, limited := classCapacity(class)
+	if !limited {
+		return nil
+	}
+	members := 0
+	tx.Range(func(student Student) bool {
+		if !student.IsDeleted && strings.EqualFold(student.Class, class) {
+			members++
+		}
+		return true
+	})
+	if members > capacity {
+		return &classFullError{Class: class, Capacity: capacity}
+	}
+	return nil
+}
+
+// writeClassFull replies 409 for a write refused by checkCapacity
+func writeClassFull(w http.ResponseWriter, full *classFullError) {
+	writeError(w, http.StatusConflict, codeClassFull, "Class "+full.Class+" is full (capacity "+strconv.Itoa(full.Capacity)+")")
+}
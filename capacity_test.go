@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// TestClassRulesIgnoreCase checks ALLOWED_CLASSES and CLASS_CAPACITY agree
+// that 5a and 5A are the same class
+func TestClassRulesIgnoreCase(t *testing.T) {
+	srv := newTestServer(t, func(c *Config) {
+		c.AllowedClasses = []string{"5A"}
+		c.ClassCapacity = map[string]int{"5A": 1}
+	})
+
+	for _, tc := range []struct {
+		class  string
+		status int
+	}{
+		{"5a", http.StatusOK},
+		{"5A", http.StatusConflict},
+		{"6A", http.StatusUnprocessableEntity},
+	} {
+		resp, body := doJSON(t, srv, http.MethodPost, "/student/v1/students", map[string]interface{}{"name": "Student " + tc.class, "age": 10, "class": tc.class})
+		if resp.StatusCode != tc.status {
+			t.Errorf("create in %s: status %d: %s, want %d", tc.class, resp.StatusCode, body, tc.status)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// POST /student/v1/classes/{class}/promote - Move every active student in a
+// class, matched case-insensitively, to {"to_class": "..."} in one
+// all-or-nothing write. Each move is recorded in the students' history.
+func promoteClass(w http.ResponseWriter, r *http.Request) {
+	from := mux.Vars(r)["class"]
+
+	var req struct {
+		ToClass string `json:"to_class"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ErrorLogger.Printf("Failed to decode request body: %v", err)
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
+		return
+	}
+
+	errs := checkClass(nil, "to_class", req.ToClass)
+	if strings.TrimSpace(req.ToClass) == "" {
+		errs = append(errs, newFieldError("to_class", fieldRequired))
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, r, &validationError{Fields: errs})
+		return
+	}
+
+	var promoted []Student
+	err = store.Exclusive(func(tx storeTx) error {
+		tx.Range(func(student Student) bool {
+			if !student.IsDeleted && strings.EqualFold(student.Class, from) {
+				promoted = append(promoted, student)
+			}
+			return true
+		})
+		if len(promoted) == 0 {
+			return errStudentNotFound
+		}
+
+		updatedAt := timestamp()
+		for i := range promoted {
+			promoted[i].Class = req.ToClass
+			if errs := checkRules(nil, promoted[i]); len(errs) > 0 {
+				return &validationError{Fields: errs}
+			}
+			promoted[i].UpdatedAt = updatedAt
+			tx.Put(promoted[i])
+		}
+		// Check only once everyone has moved, so classmates don't collide
+		// with each other's old records; any conflict rolls the whole move back
+		for _, student := range promoted {
+			if err := checkUnique(tx, student, student.EnrollmentNumber); err != nil {
+				return err
+			}
+		}
+		if strings.EqualFold(from, req.ToClass) {
+			return nil
+		}
+		return checkClassSize(tx, req.ToClass)
+	})
+	var full *classFullError
+	var verr *validationError
+	switch {
+	case errors.Is(err, errStudentNotFound):
+		writeError(w, http.StatusNotFound, codeStudentNotFound, "No students in class "+from)
+		return
+	case errors.Is(err, errDuplicateStudent):
+		writeError(w, http.StatusConflict, codeDuplicateStudent, "Promotion would duplicate a student already in "+req.ToClass)
+		return
+	case errors.As(err, &full):
+		writeClassFull(w, full)
+		return
+	case errors.As(err, &verr):
+		writeValidationError(w, r, verr)
+		return
+	case err != nil:
+		ErrorLogger.Printf("Failed to promote class %s: %v", from, err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Internal server error")
+		return
+	}
+
+	for _, student := range promoted {
+		notifyWebhook(eventStudentUpdated, student)
+	}
+
+	InfoLogger.Printf("Promoted %d students from class %s to %s", len(promoted), from, req.ToClass)
+	writeJSON(w, http.StatusOK, map[string]int{"promoted": len(promoted)})
+}
+
+// parseSubjectClassMap parses a SUBJECT_CLASS_MAP value such as
+// "Calculus:12A,Physics:12B"
+func parseSubjectClassMap(raw string) (map[string]string, error) {
+	classes := make(map[string]string)
+	for _, entry := range strings.Split(raw, ",") {
+		subject, class, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		subject, class = strings.TrimSpace(subject), strings.TrimSpace(class)
+		if !ok || subject == "" || class == "" {
+			return nil, fmt.Errorf("entry %q must look like Calculus:12A", entry)
+		}
+		classes[subject] = class
+	}
+	return classes, nil
+}
+
+// inferClass fills in a new student's missing class from the first of its
+// subjects listed in SUBJECT_CLASS_MAP, matched case-insensitively. Students
+// that already have a class, or have no mapped subject, are returned as is;
+// validateNewStudent then rejects the latter while a map is configured.
+func inferClass(student Student) Student {
+	if student.Class != "" || len(cfg.SubjectClassMap) == 0 {
+		return student
+	}
+	for _, subject := range student.Subjects {
+		for mapped, class := range cfg.SubjectClassMap {
+			if strings.EqualFold(mapped, subject) {
+				student.Class = class
+				return student
+			}
+		}
+	}
+	return student
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPromoteReportsStoreFailure(t *testing.T) {
+	srv := newTestServer(t, nil)
+	mustCreate(t, srv, map[string]interface{}{"name": "Ann", "age": 10, "class": "5A"})
+
+	store = failingStore{store.(*studentStore)}
+	resp, body := doJSON(t, srv, http.MethodPost, "/student/v1/classes/5A/promote", `{"to_class":"6A"}`)
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("promote with a failing store: status %d: %s, want 500", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,212 @@
+// Package client is a typed Go client for the student API. It speaks the
+// server's default snake_case JSON and asks for bare (unenveloped) lists, so
+// it works whatever LIST_ENVELOPE is set to, but not with JSON_NAMING=camel.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sentinel errors matched by errors.Is against an *Error
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("validation failed")
+)
+
+// Student mirrors the server's student representation
+type Student struct {
+	EnrollmentNumber string `json:"enrollment_number,omitempty"`
+	Name             string `json:"name"`
+	// Age is derived by the server when DateOfBirth (YYYY-MM-DD) is set
+	Age         int      `json:"age"`
+	DateOfBirth string   `json:"date_of_birth,omitempty"`
+	Class       string   `json:"class"`
+	Subjects    []string `json:"subjects"`
+
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+
+	// Set only on soft-deleted students, listed with IncludeDeleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy string     `json:"deleted_by,omitempty"`
+}
+
+// FieldError is one field rejected by the server's validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is a non-2xx reply, decoded from the server's JSON error body
+type Error struct {
+	StatusCode int
+	// Code is the server's machine-readable error code, e.g. STUDENT_NOT_FOUND
+	Code    string       `json:"code"`
+	Message string       `json:"error"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("student api: %d %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("student api: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Is maps HTTP statuses onto the sentinel errors
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrValidation:
+		return e.StatusCode == http.StatusUnprocessableEntity
+	}
+	return false
+}
+
+// Client calls a student API server. The zero value is not usable; create
+// one with New.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New returns a client for the server at baseURL, e.g. "http://localhost:8080".
+// A nil httpClient means http.DefaultClient.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), httpClient: httpClient}
+}
+
+// ListOptions narrows ListStudents; zero fields are not sent
+type ListOptions struct {
+	// Q is a case-insensitive substring matched against name, class or subjects
+	Q string
+	// Classes matches students in any of the listed classes
+	Classes        []string
+	Subject        string
+	Limit          int
+	Offset         int
+	IncludeDeleted bool
+}
+
+func (o ListOptions) values() url.Values {
+	v := url.Values{}
+	if o.Q != "" {
+		v.Set("q", o.Q)
+	}
+	for _, class := range o.Classes {
+		v.Add("class", class)
+	}
+	if o.Subject != "" {
+		v.Set("subject", o.Subject)
+	}
+	if o.Limit > 0 {
+		v.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		v.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if o.IncludeDeleted {
+		v.Set("include_deleted", "true")
+	}
+	return v
+}
+
+// CreateStudent creates s and returns its enrollment number, which the
+// server generates when s doesn't carry one
+func (c *Client) CreateStudent(ctx context.Context, s Student) (string, error) {
+	var created struct {
+		EnrollmentNumber string `json:"enrollment_number"`
+	}
+	err := c.do(ctx, http.MethodPost, "/student/v1/students", s, &created)
+	return created.EnrollmentNumber, err
+}
+
+// GetStudent fetches the active student with enrollment number id
+func (c *Client) GetStudent(ctx context.Context, id string) (Student, error) {
+	var s Student
+	err := c.do(ctx, http.MethodGet, "/student/v1/students/"+url.PathEscape(id), nil, &s)
+	return s, err
+}
+
+// ListStudents returns the students matching opts, ordered by enrollment
+// number. The server caps how many one call returns; page with Limit and
+// Offset for large results.
+func (c *Client) ListStudents(ctx context.Context, opts ListOptions) ([]Student, error) {
+	path := "/student/v1/students"
+	if q := opts.values().Encode(); q != "" {
+		path += "?" + q
+	}
+	var students []Student
+	err := c.do(ctx, http.MethodGet, path, nil, &students)
+	return students, err
+}
+
+// DeleteStudent soft-deletes the student with enrollment number id
+func (c *Client) DeleteStudent(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/student/v1/students/"+url.PathEscape(id), nil, nil)
+}
+
+// do sends a JSON request and decodes a 2xx reply into out, or any other
+// reply into an *Error
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", `application/json; profile="bare"`)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		// Servers with ERROR_FORMAT=problem send the message as detail
+		body := struct {
+			*Error
+			Detail string `json:"detail"`
+		}{Error: apiErr}
+		if json.NewDecoder(resp.Body).Decode(&body) == nil && apiErr.Message == "" {
+			apiErr.Message = body.Detail
+		}
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
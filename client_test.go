@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"student-api/client"
+)
+
+// newTestClient points a client.Client at newTestServer
+func newTestClient(t *testing.T, configure func(*Config)) *client.Client {
+	t.Helper()
+	srv := newTestServer(t, configure)
+	return client.New(srv.URL, srv.Client())
+}
+
+func TestClientCreateGetListDelete(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		configure func(*Config)
+	}{
+		{"defaults", nil},
+		{"list envelope", func(c *Config) { c.ListEnvelope = true }},
+		{"problem errors", func(c *Config) { c.ErrorFormat = errorFormatProblem }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestClient(t, tc.configure)
+			ctx := context.Background()
+
+			id, err := c.CreateStudent(ctx, client.Student{Name: "Ann", Age: 10, Class: "5A", Subjects: []string{"Math"}})
+			if err != nil {
+				t.Fatalf("CreateStudent: %v", err)
+			}
+			if _, err := c.CreateStudent(ctx, client.Student{Name: "Ben", Age: 11, Class: "6B"}); err != nil {
+				t.Fatalf("CreateStudent: %v", err)
+			}
+
+			got, err := c.GetStudent(ctx, id)
+			if err != nil {
+				t.Fatalf("GetStudent: %v", err)
+			}
+			if got.EnrollmentNumber != id || got.Name != "Ann" || len(got.Subjects) != 1 || got.Subjects[0] != "Math" {
+				t.Errorf("GetStudent = %+v", got)
+			}
+
+			all, err := c.ListStudents(ctx, client.ListOptions{})
+			if err != nil {
+				t.Fatalf("ListStudents: %v", err)
+			}
+			if len(all) != 2 {
+				t.Errorf("ListStudents returned %d students, want 2", len(all))
+			}
+			filtered, err := c.ListStudents(ctx, client.ListOptions{Classes: []string{"6B"}})
+			if err != nil {
+				t.Fatalf("ListStudents(class=6B): %v", err)
+			}
+			if len(filtered) != 1 || filtered[0].Name != "Ben" {
+				t.Errorf("ListStudents(class=6B) = %+v", filtered)
+			}
+
+			if err := c.DeleteStudent(ctx, id); err != nil {
+				t.Fatalf("DeleteStudent: %v", err)
+			}
+			_, err = c.GetStudent(ctx, id)
+			if !errors.Is(err, client.ErrNotFound) {
+				t.Fatalf("GetStudent after delete: %v, want ErrNotFound", err)
+			}
+			var apiErr *client.Error
+			if !errors.As(err, &apiErr) || apiErr.Code != codeStudentNotFound || apiErr.Message == "" {
+				t.Errorf("GetStudent after delete: %#v, want a %s error with a message", err, codeStudentNotFound)
+			}
+		})
+	}
+}
+
+func TestClientTypedErrors(t *testing.T) {
+	c := newTestClient(t, nil)
+	ctx := context.Background()
+
+	_, err := c.CreateStudent(ctx, client.Student{Name: "", Age: 0})
+	if !errors.Is(err, client.ErrValidation) {
+		t.Fatalf("CreateStudent(invalid): %v, want ErrValidation", err)
+	}
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || len(apiErr.Fields) == 0 {
+		t.Errorf("CreateStudent(invalid): %#v, want field errors", err)
+	}
+
+	student := client.Student{EnrollmentNumber: "E1", Name: "Ann", Age: 10, Class: "5A"}
+	if _, err := c.CreateStudent(ctx, student); err != nil {
+		t.Fatalf("CreateStudent: %v", err)
+	}
+	if _, err := c.CreateStudent(ctx, student); !errors.Is(err, client.ErrConflict) {
+		t.Errorf("CreateStudent(duplicate): %v, want ErrConflict", err)
+	}
+
+	if err := c.DeleteStudent(ctx, "no-such-student"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("DeleteStudent(unknown): %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// now is the clock behind every timestamp the API records. It is a variable
+// so tests can freeze time and assert on created_at/updated_at.
+var now = time.Now
+
+// timestamp returns the current time from now, normalized to UTC
+func timestamp() time.Time {
+	return now().UTC()
+}
+
+// GET /time - The server's current time as timestamp records it, so clients
+// can measure their clock skew before comparing created_at and updated_at or
+// choosing a modified_since. Recorded timestamps are always UTC, whatever
+// the host's zone, which is reported separately as local_timezone.
+func getTime(w http.ResponseWriter, r *http.Request) {
+	t := timestamp()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"time":           t.Format(time.RFC3339Nano),
+		"unix_ms":        t.UnixMilli(),
+		"timezone":       t.Location().String(),
+		"local_timezone": time.Local.String(),
+	})
+}
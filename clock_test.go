@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// freezeClock makes now return at until the test ends
+func freezeClock(t *testing.T, at time.Time) {
+	t.Helper()
+	saved := now
+	now = func() time.Time { return at }
+	t.Cleanup(func() { now = saved })
+}
+
+// studentTimes reads the timestamps of id as the API returns them
+func studentTimes(t *testing.T, srv *httptest.Server, id string) (created, updated string) {
+	t.Helper()
+	resp, body := doJSON(t, srv, http.MethodGet, "/student/v1/students/"+id, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: status %d: %s", id, resp.StatusCode, body)
+	}
+	var times struct {
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+	}
+	if err := json.Unmarshal(body, &times); err != nil {
+		t.Fatal(err)
+	}
+	return times.CreatedAt, times.UpdatedAt
+}
+
+func TestTimestamps(t *testing.T) {
+	srv := newTestServer(t, nil)
+	zone := time.FixedZone("UTC+2", 2*60*60)
+
+	freezeClock(t, time.Date(2024, 3, 1, 11, 30, 0, 0, zone))
+	id := mustCreate(t, srv, map[string]interface{}{"name": "Ann", "age": 10, "class": "5A"})
+	if created, updated := studentTimes(t, srv, id); created != "2024-03-01T09:30:00Z" || updated != created {
+		t.Errorf("after create: created_at %s updated_at %s, want both 2024-03-01T09:30:00Z", created, updated)
+	}
+
+	freezeClock(t, time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC))
+	resp, body := doJSON(t, srv, http.MethodPut, "/student/v1/students/"+id, map[string]interface{}{"name": "Ann", "age": 11, "class": "5A"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT: status %d: %s", resp.StatusCode, body)
+	}
+	if created, updated := studentTimes(t, srv, id); created != "2024-03-01T09:30:00Z" || updated != "2024-03-02T08:00:00Z" {
+		t.Errorf("after update: created_at %s updated_at %s, want 2024-03-01T09:30:00Z and 2024-03-02T08:00:00Z", created, updated)
+	}
+
+	freezeClock(t, time.Date(2024, 3, 3, 8, 0, 0, 0, time.UTC))
+	resp, body = doJSON(t, srv, http.MethodPatch, "/student/v1/students/"+id, `{"class":"5B"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PATCH: status %d: %s", resp.StatusCode, body)
+	}
+	if created, updated := studentTimes(t, srv, id); created != "2024-03-01T09:30:00Z" || updated != "2024-03-03T08:00:00Z" {
+		t.Errorf("after patch: created_at %s updated_at %s, want 2024-03-01T09:30:00Z and 2024-03-03T08:00:00Z", created, updated)
+	}
+
+	resp, body = doJSON(t, srv, http.MethodGet, "/time", nil)
+	var clock struct {
+		Time string `json:"time"`
+	}
+	json.Unmarshal(body, &clock)
+	if resp.StatusCode != http.StatusOK || clock.Time != "2024-03-03T08:00:00Z" {
+		t.Errorf("GET /time: status %d: %s, want 2024-03-03T08:00:00Z", resp.StatusCode, body)
+	}
+}
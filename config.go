@@ -0,0 +1,599 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Address the HTTP server listens on
+const listenAddr = ":8080"
+
+// Config is every tunable the service reads from the environment. It is
+// parsed once at startup by loadConfig; the env var for each field is named
+// in its comment, and the optional CONFIG_FILE may set the same fields by
+// JSON name. Fields tagged secret are masked when logged.
+type Config struct {
+	// Query string limits, see queryLimitMiddleware
+	MaxQueryLength int `json:"max_query_length"` // MAX_QUERY_LENGTH
+	MaxQueryParams int `json:"max_query_params"` // MAX_QUERY_PARAMS
+
+	// Maximum field lengths, in characters, enforced by validateStudent
+	MaxNameLength    int `json:"max_name_length"`    // MAX_NAME_LENGTH
+	MaxClassLength   int `json:"max_class_length"`   // MAX_CLASS_LENGTH
+	MaxSubjectLength int `json:"max_subject_length"` // MAX_SUBJECT_LENGTH
+	// Limits on a student's free-form tags, see checkTags
+	MaxTags           int `json:"max_tags"`             // MAX_TAGS
+	MaxTagKeyLength   int `json:"max_tag_key_length"`   // MAX_TAG_KEY_LENGTH
+	MaxTagValueLength int `json:"max_tag_value_length"` // MAX_TAG_VALUE_LENGTH
+	// Maximum length of the reason given for a soft delete
+	MaxDeleteReasonLength int `json:"max_delete_reason_length"` // MAX_DELETE_REASON_LENGTH
+	// Classes a student may be placed in, ignoring case; empty accepts any
+	AllowedClasses []string `json:"allowed_classes"` // ALLOWED_CLASSES
+	// Most active students per class, see checkCapacity; unlisted classes are
+	// unlimited
+	ClassCapacity map[string]int `json:"class_capacity"` // CLASS_CAPACITY
+	// Class given to new students created without one, by subject, see
+	// inferClass; unset keeps class optional
+	SubjectClassMap map[string]string `json:"subject_class_map"` // SUBJECT_CLASS_MAP
+	// Deployment-specific constraints on top of the built-in ones, see
+	// checkRules
+	ValidationRules []validationRule `json:"validation_rules"` // VALIDATION_RULES
+
+	// How search and filters compare text, see searchKey
+	SearchMatching string `json:"search_matching"` // SEARCH_MATCHING
+
+	// Hard cap on records serialized by a single list response, 0 disables it
+	MaxListResults int `json:"max_list_results"` // MAX_LIST_RESULTS
+	// Order of list results, see sortStudents
+	DefaultSort string `json:"default_sort"` // DEFAULT_SORT
+	// Whether unfiltered lists are served from the store's cached snapshot
+	ListSnapshot bool `json:"list_snapshot"` // LIST_SNAPSHOT
+	// How long encoded list responses are reused, 0 disables the cache
+	ListCacheTTL time.Duration `json:"list_cache_ttl"` // LIST_CACHE_TTL
+	// Whether list endpoints default to a {"data": [...]} envelope
+	ListEnvelope bool `json:"list_envelope"` // LIST_ENVELOPE
+
+	// Fraction of successful requests given an access log line, from 0 to 1;
+	// errors and slow requests are always logged
+	LogSampleRate float64 `json:"log_sample_rate"` // LOG_SAMPLE_RATE
+	// Debug logging of request and response bodies, see bodyLogMiddleware.
+	// Off by default: bodies may hold personal data.
+	LogBodies       bool     `json:"log_bodies"`         // LOG_BODIES
+	LogBodyMaxBytes int      `json:"log_body_max_bytes"` // LOG_BODY_MAX_BYTES
+	LogRedactFields []string `json:"log_redact_fields"`  // LOG_REDACT_FIELDS
+	// Requests taking longer than this are logged as warnings, 0 disables it
+	SlowRequestThreshold time.Duration `json:"slow_request_ms"` // SLOW_REQUEST_MS
+
+	// Most requests served at once, 0 means unlimited
+	MaxConcurrent int `json:"max_concurrent"` // MAX_CONCURRENT
+
+	// Deadline for requests without X-Request-Timeout-Ms, and the most a client
+	// may ask for; 0 means none
+	RequestTimeout    time.Duration `json:"request_timeout"`     // REQUEST_TIMEOUT
+	MaxRequestTimeout time.Duration `json:"max_request_timeout"` // MAX_REQUEST_TIMEOUT
+
+	// Whether startup logs the route table and probes the router, see
+	// startupSelfCheck
+	StartupSelfCheck bool `json:"startup_selfcheck"` // STARTUP_SELFCHECK
+	// How long in-flight requests get to finish once shutdown begins
+	ShutdownTimeout time.Duration `json:"shutdown_timeout"` // SHUTDOWN_TIMEOUT
+
+	// Registered storage backend to use, see RegisterStore
+	Store string `json:"store"` // STORE
+	// Whether DELETE of a student must carry If-Match
+	RequireIfMatch bool `json:"require_if_match"` // REQUIRE_IF_MATCH
+	// Uniqueness policy enforced on create and update, see checkUnique
+	UniqueBy string `json:"unique_by"` // UNIQUE_BY
+	// Whether client-supplied enrollment numbers collide regardless of case
+	EnrollmentCaseInsensitive bool `json:"enrollment_case_insensitive"` // ENROLLMENT_CASE_INSENSITIVE
+
+	// Enrollment number generation, see generateEnrollmentNumber
+	EnrollmentMode         string `json:"enrollment_mode"`          // ENROLLMENT_MODE
+	EnrollmentPrefix       string `json:"enrollment_prefix"`        // ENROLLMENT_PREFIX
+	EnrollmentPadding      int    `json:"enrollment_padding"`       // ENROLLMENT_PADDING
+	EnrollmentSequenceFile string `json:"enrollment_sequence_file"` // ENROLLMENT_SEQUENCE_FILE
+	// Generated numbers tried before a create gives up on finding a free one
+	EnrollmentIDAttempts int `json:"enrollment_id_attempts"` // ENROLLMENT_ID_ATTEMPTS
+	// Format client-supplied enrollment numbers must match in full, nil accepts any
+	EnrollmentPattern *regexp.Regexp `json:"enrollment_pattern"` // ENROLLMENT_PATTERN
+
+	// Identical create payloads within this window are rejected as
+	// double-submits, 0 disables the check
+	DuplicateWindow time.Duration `json:"duplicate_window"` // DUPLICATE_WINDOW
+
+	// How long clients may cache /stats responses, sent as Cache-Control
+	// max-age; 0 leaves the header off
+	StatsMaxAge time.Duration `json:"stats_max_age"` // STATS_MAX_AGE
+
+	// Versions kept per student for revert, 0 disables history
+	HistoryLimit int `json:"history_limit"` // HISTORY_LIMIT
+
+	// Bearer token required by /admin endpoints; empty disables them
+	AdminToken string `json:"admin_token" secret:"true"` // ADMIN_TOKEN
+	// Whether the HTML page at /admin/ui is served, only with admin enabled
+	AdminUI bool `json:"admin_ui"` // ADMIN_UI
+
+	// Whether plain-HTTP requests seen by a TLS-terminating proxy are
+	// redirected to HTTPS, see forceHTTPSMiddleware
+	ForceHTTPS bool `json:"force_https"` // FORCE_HTTPS
+
+	// Static headers added to every response, see responseHeadersMiddleware
+	ResponseHeaders map[string]string `json:"response_headers"` // RESPONSE_HEADERS
+
+	// Whether POST and PUT bodies must be sent as application/json
+	StrictContentType bool `json:"strict_content_type"` // STRICT_CONTENT_TYPE
+	// Whether GET and DELETE requests with a body are rejected, see
+	// strictBodyMiddleware
+	StrictBody bool `json:"strict_body"` // STRICT_BODY
+
+	// Whether trailing slashes are ignored when routing, see trailingSlashMiddleware
+	StrictSlash bool `json:"strict_slash"` // STRICT_SLASH
+	// Key style for JSON responses, see camelCaseMiddleware
+	JSONNaming string `json:"json_naming"` // JSON_NAMING
+	// Shape of error bodies, see problemMiddleware, and the prefix of the
+	// problem type URIs derived from error codes
+	ErrorFormat     string `json:"error_format"`      // ERROR_FORMAT
+	ProblemTypeBase string `json:"problem_type_base"` // PROBLEM_TYPE_BASE
+
+	// Outbound lifecycle webhooks, disabled when WebhookURL is empty
+	WebhookURL     string        `json:"webhook_url" secret:"url"`     // WEBHOOK_URL
+	WebhookSecret  string        `json:"webhook_secret" secret:"true"` // WEBHOOK_SECRET
+	WebhookTimeout time.Duration `json:"webhook_timeout"`              // WEBHOOK_TIMEOUT
+	WebhookRetries int           `json:"webhook_retries"`              // WEBHOOK_RETRIES
+	// Events waiting for delivery; the oldest is dropped when full
+	WebhookQueueSize int `json:"webhook_queue_size"` // WEBHOOK_QUEUE_SIZE
+	// Consecutive failed attempts that pause delivery for the cooldown, 0
+	// disables the circuit breaker
+	WebhookBreakerThreshold int           `json:"webhook_breaker_threshold"` // WEBHOOK_BREAKER_THRESHOLD
+	WebhookBreakerCooldown  time.Duration `json:"webhook_breaker_cooldown"`  // WEBHOOK_BREAKER_COOLDOWN
+	// How long events following one just sent are gathered into a single
+	// students.bulk_changed event, 0 sends every event on its own
+	WebhookCoalesceWindow time.Duration `json:"webhook_coalesce_window"` // WEBHOOK_COALESCE_WINDOW
+
+	// Rows per committed batch and between progress events of a streaming
+	// import, see importStudents
+	ImportBatchSize int `json:"import_batch_size"` // IMPORT_BATCH_SIZE
+	// Most rows an atomic import holds in memory until it commits; larger
+	// uploads must use mode=partial
+	ImportMaxAtomicRows int `json:"import_max_atomic_rows"` // IMPORT_MAX_ATOMIC_ROWS
+
+	// Fields forming the natural key matched by the sync endpoint
+	SyncKey []string `json:"sync_key"` // SYNC_KEY
+}
+
+// cfg is the configuration the running server uses. main assigns it once,
+// before serving, and nothing writes it afterwards.
+var cfg = defaultConfig()
+
+// defaultConfig returns the configuration used when no env vars are set
+func defaultConfig() Config {
+	return Config{
+		MaxQueryLength:        2048,
+		MaxQueryParams:        50,
+		MaxNameLength:         100,
+		MaxClassLength:        20,
+		MaxSubjectLength:      50,
+		MaxTags:               20,
+		MaxTagKeyLength:       64,
+		MaxTagValueLength:     256,
+		MaxDeleteReasonLength: 500,
+		SearchMatching:        searchNormalized,
+		DefaultSort:           sortByEnrollment,
+		MaxListResults:        1000,
+		ListSnapshot:          true,
+		LogSampleRate:         1,
+		LogBodyMaxBytes:       2048,
+		LogRedactFields:       []string{"password", "token", "secret"},
+		SlowRequestThreshold:  500 * time.Millisecond,
+		RequestTimeout:        30 * time.Second,
+		MaxRequestTimeout:     60 * time.Second,
+		ShutdownTimeout:       10 * time.Second,
+		Store:                 memoryStoreName,
+		UniqueBy:              uniqueByEnrollment,
+		EnrollmentMode:        enrollmentModeUUID,
+		EnrollmentPadding:     6,
+		EnrollmentIDAttempts:  5,
+		HistoryLimit:          10,
+		ResponseHeaders: map[string]string{
+			"X-Content-Type-Options": "nosniff",
+			"X-Frame-Options":        "DENY",
+			"Referrer-Policy":        "no-referrer",
+		},
+		JSONNaming:              namingSnake,
+		ErrorFormat:             errorFormatSimple,
+		ProblemTypeBase:         "urn:student-api:problem:",
+		WebhookTimeout:          5 * time.Second,
+		WebhookRetries:          3,
+		WebhookQueueSize:        1000,
+		WebhookBreakerThreshold: 5,
+		WebhookBreakerCooldown:  30 * time.Second,
+		ImportBatchSize:         1000,
+		ImportMaxAtomicRows:     100000,
+		SyncKey:                 []string{"name", "class"},
+	}
+}
+
+// loadConfig builds a Config from getenv (os.Getenv in production), starting
+// from the defaults. Every malformed or out-of-range value is reported in the
+// returned error so they can all be fixed in one go.
+func loadConfig(getenv func(string) string) (Config, error) {
+	c := defaultConfig()
+	p := envParser{getenv: getenv}
+
+	p.positiveInt("MAX_QUERY_LENGTH", &c.MaxQueryLength)
+	p.positiveInt("MAX_QUERY_PARAMS", &c.MaxQueryParams)
+	p.positiveInt("MAX_NAME_LENGTH", &c.MaxNameLength)
+	p.positiveInt("MAX_CLASS_LENGTH", &c.MaxClassLength)
+	p.positiveInt("MAX_SUBJECT_LENGTH", &c.MaxSubjectLength)
+	p.positiveInt("MAX_TAGS", &c.MaxTags)
+	p.positiveInt("MAX_TAG_KEY_LENGTH", &c.MaxTagKeyLength)
+	p.positiveInt("MAX_TAG_VALUE_LENGTH", &c.MaxTagValueLength)
+	p.positiveInt("MAX_DELETE_REASON_LENGTH", &c.MaxDeleteReasonLength)
+	p.list("ALLOWED_CLASSES", &c.AllowedClasses)
+	p.choice("SEARCH_MATCHING", &c.SearchMatching, searchNormalized, searchStrict)
+	p.nonNegativeInt("MAX_LIST_RESULTS", &c.MaxListResults)
+	p.choice("DEFAULT_SORT", &c.DefaultSort, sortByEnrollment, sortByName, sortByCreated)
+	p.bool("LIST_SNAPSHOT", &c.ListSnapshot)
+	p.duration("LIST_CACHE_TTL", &c.ListCacheTTL)
+	p.bool("LIST_ENVELOPE", &c.ListEnvelope)
+	p.fraction("LOG_SAMPLE_RATE", &c.LogSampleRate)
+	p.bool("LOG_BODIES", &c.LogBodies)
+	p.positiveInt("LOG_BODY_MAX_BYTES", &c.LogBodyMaxBytes)
+	p.list("LOG_REDACT_FIELDS", &c.LogRedactFields)
+	p.millis("SLOW_REQUEST_MS", &c.SlowRequestThreshold)
+	p.nonNegativeInt("MAX_CONCURRENT", &c.MaxConcurrent)
+	p.duration("REQUEST_TIMEOUT", &c.RequestTimeout)
+	p.duration("MAX_REQUEST_TIMEOUT", &c.MaxRequestTimeout)
+	p.bool("STARTUP_SELFCHECK", &c.StartupSelfCheck)
+	p.duration("SHUTDOWN_TIMEOUT", &c.ShutdownTimeout)
+	p.string("STORE", &c.Store)
+	p.bool("REQUIRE_IF_MATCH", &c.RequireIfMatch)
+	p.choice("UNIQUE_BY", &c.UniqueBy, uniqueByEnrollment, uniqueByNameClass, uniqueByNone)
+	p.bool("ENROLLMENT_CASE_INSENSITIVE", &c.EnrollmentCaseInsensitive)
+	p.choice("ENROLLMENT_MODE", &c.EnrollmentMode, enrollmentModeUUID, enrollmentModeSequence)
+	p.string("ENROLLMENT_PREFIX", &c.EnrollmentPrefix)
+	if c.EnrollmentPrefix != "" && !validEnrollmentPrefix(c.EnrollmentPrefix) {
+		p.fail("ENROLLMENT_PREFIX", c.EnrollmentPrefix, fmt.Sprintf("must be alphanumeric and at most %d characters", maxEnrollmentPrefixLength))
+	}
+	p.nonNegativeInt("ENROLLMENT_PADDING", &c.EnrollmentPadding)
+	p.string("ENROLLMENT_SEQUENCE_FILE", &c.EnrollmentSequenceFile)
+	p.positiveInt("ENROLLMENT_ID_ATTEMPTS", &c.EnrollmentIDAttempts)
+	p.pattern("ENROLLMENT_PATTERN", &c.EnrollmentPattern)
+	p.duration("DUPLICATE_WINDOW", &c.DuplicateWindow)
+	p.duration("STATS_MAX_AGE", &c.StatsMaxAge)
+	p.nonNegativeInt("HISTORY_LIMIT", &c.HistoryLimit)
+	p.string("ADMIN_TOKEN", &c.AdminToken)
+	p.bool("ADMIN_UI", &c.AdminUI)
+	p.bool("FORCE_HTTPS", &c.ForceHTTPS)
+	p.headers("RESPONSE_HEADERS", c.ResponseHeaders)
+	p.bool("STRICT_CONTENT_TYPE", &c.StrictContentType)
+	p.bool("STRICT_BODY", &c.StrictBody)
+	p.bool("STRICT_SLASH", &c.StrictSlash)
+	p.choice("JSON_NAMING", &c.JSONNaming, namingSnake, namingCamel)
+	p.choice("ERROR_FORMAT", &c.ErrorFormat, errorFormatSimple, errorFormatProblem)
+	p.string("PROBLEM_TYPE_BASE", &c.ProblemTypeBase)
+	p.string("WEBHOOK_URL", &c.WebhookURL)
+	if c.WebhookURL != "" {
+		if u, err := url.Parse(c.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			p.fail("WEBHOOK_URL", maskURL(c.WebhookURL), "must be an absolute http or https URL")
+		}
+	}
+	p.string("WEBHOOK_SECRET", &c.WebhookSecret)
+	p.duration("WEBHOOK_TIMEOUT", &c.WebhookTimeout)
+	p.nonNegativeInt("WEBHOOK_RETRIES", &c.WebhookRetries)
+	p.positiveInt("WEBHOOK_QUEUE_SIZE", &c.WebhookQueueSize)
+	p.nonNegativeInt("WEBHOOK_BREAKER_THRESHOLD", &c.WebhookBreakerThreshold)
+	p.duration("WEBHOOK_BREAKER_COOLDOWN", &c.WebhookBreakerCooldown)
+	p.duration("WEBHOOK_COALESCE_WINDOW", &c.WebhookCoalesceWindow)
+	p.positiveInt("IMPORT_BATCH_SIZE", &c.ImportBatchSize)
+	p.positiveInt("IMPORT_MAX_ATOMIC_ROWS", &c.ImportMaxAtomicRows)
+	if raw := getenv("CLASS_CAPACITY"); raw != "" {
+		if capacities, err := parseClassCapacity(raw); err == nil {
+			c.ClassCapacity = capacities
+		} else {
+			p.fail("CLASS_CAPACITY", raw, err.Error())
+		}
+	}
+	if raw := getenv("SUBJECT_CLASS_MAP"); raw != "" {
+		if classes, err := parseSubjectClassMap(raw); err == nil {
+			c.SubjectClassMap = classes
+		} else {
+			p.fail("SUBJECT_CLASS_MAP", raw, err.Error())
+		}
+	}
+	if raw := getenv("VALIDATION_RULES"); raw != "" {
+		if rules, err := parseValidationRules(raw); err == nil {
+			c.ValidationRules = rules
+		} else {
+			p.fail("VALIDATION_RULES", raw, err.Error())
+		}
+	}
+	if raw := getenv("SYNC_KEY"); raw != "" {
+		if fields, err := parseSyncKey(raw); err == nil {
+			c.SyncKey = fields
+		} else {
+			p.fail("SYNC_KEY", raw, err.Error())
+		}
+	}
+
+	return c, p.err()
+}
+
+// mustLoadConfig loads cfg from the process environment, exiting with a clear
+// message when any value is malformed. When CONFIG_FILE names a file, its
+// values fill in for env vars that are unset or empty.
+func mustLoadConfig() {
+	getenv := os.Getenv
+	path := os.Getenv("CONFIG_FILE")
+	if path != "" {
+		values, err := loadConfigFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			ErrorLogger.Fatalln(err)
+		}
+		getenv = func(key string) string {
+			if value := os.Getenv(key); value != "" {
+				return value
+			}
+			return values[key]
+		}
+		InfoLogger.Printf("Loaded %d settings from config file %s", len(values), path)
+	}
+
+	c, err := loadConfig(getenv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		ErrorLogger.Fatalln(err)
+	}
+	cfg = c
+	logConfig(cfg)
+}
+
+// envParser reads typed values from the environment, collecting a message for
+// every value it can't use instead of stopping at the first
+type envParser struct {
+	getenv func(string) string
+	errs   []string
+}
+
+func (p *envParser) fail(key, value, reason string) {
+	p.errs = append(p.errs, fmt.Sprintf("%s=%q: %s", key, value, reason))
+}
+
+func (p *envParser) err() error {
+	if len(p.errs) == 0 {
+		return nil
+	}
+	return errors.New("invalid configuration: " + strings.Join(p.errs, "; "))
+}
+
+func (p *envParser) string(key string, dst *string) {
+	if value := p.getenv(key); value != "" {
+		*dst = value
+	}
+}
+
+func (p *envParser) int(key string, dst *int, min int) {
+	value := p.getenv(key)
+	if value == "" {
+		return
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		p.fail(key, value, "must be an integer")
+		return
+	}
+	if n < min {
+		p.fail(key, value, fmt.Sprintf("must be at least %d", min))
+		return
+	}
+	*dst = n
+}
+
+func (p *envParser) positiveInt(key string, dst *int) {
+	p.int(key, dst, 1)
+}
+
+func (p *envParser) nonNegativeInt(key string, dst *int) {
+	p.int(key, dst, 0)
+}
+
+// list accepts comma-separated values, trimmed and with empty ones dropped
+func (p *envParser) list(key string, dst *[]string) {
+	value := p.getenv(key)
+	if value == "" {
+		return
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	if len(list) == 0 {
+		p.fail(key, value, "must list at least one value")
+		return
+	}
+	*dst = list
+}
+
+// duration accepts values such as "10s" or "1m"
+func (p *envParser) duration(key string, dst *time.Duration) {
+	value := p.getenv(key)
+	if value == "" {
+		return
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		p.fail(key, value, "must be a non-negative duration such as 10s")
+		return
+	}
+	*dst = d
+}
+
+// millis accepts a whole number of milliseconds
+func (p *envParser) millis(key string, dst *time.Duration) {
+	ms := int(*dst / time.Millisecond)
+	p.nonNegativeInt(key, &ms)
+	*dst = time.Duration(ms) * time.Millisecond
+}
+
+// fraction accepts a number from 0 to 1, such as "0.1"
+func (p *envParser) fraction(key string, dst *float64) {
+	value := p.getenv(key)
+	if value == "" {
+		return
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 || f > 1 {
+		p.fail(key, value, "must be a number between 0 and 1")
+		return
+	}
+	*dst = f
+}
+
+// bool accepts values such as "true" or "0"
+func (p *envParser) bool(key string, dst *bool) {
+	value := p.getenv(key)
+	if value == "" {
+		return
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		p.fail(key, value, "must be a boolean")
+		return
+	}
+	*dst = b
+}
+
+// choice accepts one of a fixed set of values
+func (p *envParser) choice(key string, dst *string, choices ...string) {
+	value := p.getenv(key)
+	if value == "" {
+		return
+	}
+
+	for _, choice := range choices {
+		if value == choice {
+			*dst = value
+			return
+		}
+	}
+	p.fail(key, value, "must be one of "+strings.Join(choices, ", "))
+}
+
+// pattern accepts a regular expression, anchored so it must match a whole value
+func (p *envParser) pattern(key string, dst **regexp.Regexp) {
+	value := p.getenv(key)
+	if value == "" {
+		return
+	}
+
+	re, err := regexp.Compile(`^(?:` + value + `)$`)
+	if err != nil {
+		p.fail(key, value, "must be a valid regular expression")
+		return
+	}
+	*dst = re
+}
+
+// headers merges a JSON object of header names to values into dst, such as
+// {"X-Frame-Options":"SAMEORIGIN"}. An empty value removes a header. CORS
+// headers are refused, since they depend on the request and can't be static.
+func (p *envParser) headers(key string, dst map[string]string) {
+	value := p.getenv(key)
+	if value == "" {
+		return
+	}
+
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(value), &headers); err != nil {
+		p.fail(key, value, `must be a JSON object such as {"X-Frame-Options":"DENY"}`)
+		return
+	}
+	for name, v := range headers {
+		canonical := http.CanonicalHeaderKey(name)
+		switch {
+		case name == "" || strings.ContainsAny(name, " :\r\n") || strings.ContainsAny(v, "\r\n"):
+			p.fail(key, value, fmt.Sprintf("invalid header %q", name))
+		case strings.HasPrefix(canonical, "Access-Control-"):
+			p.fail(key, value, fmt.Sprintf("%s is a CORS header and can't be set statically", canonical))
+		case v == "":
+			delete(dst, canonical)
+		default:
+			dst[canonical] = v
+		}
+	}
+}
+
+// logConfig writes the effective configuration as a single JSON log line so
+// operators can check what the environment resolved to. Secrets are masked.
+func logConfig(c Config) {
+	summary := map[string]interface{}{
+		"listen_addr":   listenAddr,
+		"admin_enabled": c.AdminToken != "",
+	}
+
+	v := reflect.ValueOf(c)
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		value := v.Field(i).Interface()
+
+		switch field.Tag.Get("secret") {
+		case "true":
+			value = maskSecret(value.(string))
+		case "url":
+			value = maskURL(value.(string))
+		}
+		switch v := value.(type) {
+		case time.Duration:
+			value = v.String()
+		case *regexp.Regexp:
+			// A Regexp has no exported fields and would encode as {}
+			value = ""
+			if v != nil {
+				value = v.String()
+			}
+		}
+		summary[name] = value
+	}
+
+	encoded, err := json.Marshal(summary)
+	if err != nil {
+		ErrorLogger.Printf("Failed to encode configuration summary: %v", err)
+		return
+	}
+	InfoLogger.Printf("Effective configuration: %s", encoded)
+}
+
+// maskSecret hides a secret while still showing whether it is set
+func maskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "****"
+}
+
+// maskURL hides any password embedded in a URL
+func maskURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return maskSecret(raw)
+	}
+	return u.Redacted()
+}
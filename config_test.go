@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLogConfigShowsPatterns(t *testing.T) {
+	var buf bytes.Buffer
+	saved := InfoLogger.Writer()
+	InfoLogger.SetOutput(&buf)
+	t.Cleanup(func() { InfoLogger.SetOutput(saved) })
+
+	c := defaultConfig()
+	logConfig(c)
+	if !strings.Contains(buf.String(), `"enrollment_pattern":""`) {
+		t.Errorf("summary without a pattern = %s, want enrollment_pattern empty", buf.String())
+	}
+
+	buf.Reset()
+	c.EnrollmentPattern = regexp.MustCompile(`^(?:E\d{6})$`)
+	logConfig(c)
+	if !strings.Contains(buf.String(), `"enrollment_pattern":"^(?:E\\d{6})$"`) {
+		t.Errorf("summary = %s, want enrollment_pattern as its source", buf.String())
+	}
+}
+
+// TestConfigFileObjects gives the pair-valued settings as objects in both
+// config file formats
+func TestConfigFileObjects(t *testing.T) {
+	for name, contents := range map[string]string{
+		"config.json": `{"class_capacity": {"10A": 30, "10B": 25}, "subject_class_map": {"Calculus": "12A"}}`,
+		"config.yaml": "class_capacity: {\"10A\": 30, \"10B\": 25}\nsubject_class_map: {\"Calculus\": \"12A\"}\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			values, err := loadConfigFile(path)
+			if err != nil {
+				t.Fatalf("loadConfigFile: %v", err)
+			}
+			if got := values["CLASS_CAPACITY"]; got != "10A:30,10B:25" {
+				t.Errorf("CLASS_CAPACITY = %q, want 10A:30,10B:25", got)
+			}
+
+			c, err := loadConfig(func(key string) string { return values[key] })
+			if err != nil {
+				t.Fatalf("loadConfig: %v", err)
+			}
+			if c.ClassCapacity["10A"] != 30 || c.ClassCapacity["10B"] != 25 || c.SubjectClassMap["Calculus"] != "12A" {
+				t.Errorf("class_capacity %v subject_class_map %v, want the file's objects", c.ClassCapacity, c.SubjectClassMap)
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"class_capacity": {"10A": "many"}}`), 0o600)
+	values, err := loadConfigFile(path)
+	if err == nil {
+		_, err = loadConfig(func(key string) string { return values[key] })
+	}
+	if err == nil || !strings.Contains(err.Error(), "CLASS_CAPACITY") {
+		t.Errorf("a non-numeric capacity gave %v, want an error naming CLASS_CAPACITY", err)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// loadConfigFile reads the optional CONFIG_FILE, a JSON object or flat YAML
+// mapping keyed by Config's JSON names, such as {"max_name_length": 80}.
+// Values are returned keyed by env var name as the text that env var would
+// hold, so one set of parsing and validation rules covers both sources:
+// durations are strings such as "10s", lists such as sync_key may be arrays,
+// and response_headers is an object (in YAML, written as an inline JSON
+// object). class_capacity and subject_class_map may be objects such as
+// {"10A": 30} or their env var form, "10A:30". Unknown keys are an error, so
+// typos don't go unnoticed.
+func loadConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+
+	var values map[string]string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		values, err = parseJSONConfig(data)
+	case ".yaml", ".yml":
+		values, err = parseYAMLConfig(data)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported extension %q, want .json, .yaml or .yml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	known := configKeys()
+	env := make(map[string]string, len(values))
+	var unknown []string
+	for key, value := range values {
+		if !known[key] {
+			unknown = append(unknown, key)
+			continue
+		}
+		if pairConfigKeys[key] && strings.HasPrefix(value, "{") {
+			if value, err = flattenPairs(value); err != nil {
+				return nil, fmt.Errorf("config file %s: %s: %v", path, key, err)
+			}
+		}
+		env[strings.ToUpper(key)] = value
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("config file %s: unknown keys %s", path, strings.Join(unknown, ", "))
+	}
+	return env, nil
+}
+
+// configKeys returns the JSON names of Config's fields, which upper-cased are
+// the env vars loadConfig reads
+func configKeys() map[string]bool {
+	keys := make(map[string]bool)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		keys[strings.Split(t.Field(i).Tag.Get("json"), ",")[0]] = true
+	}
+	return keys
+}
+
+// Keys whose env var holds comma-separated key:value pairs, such as
+// CLASS_CAPACITY=10A:30,10B:25
+var pairConfigKeys = map[string]bool{
+	"class_capacity":    true,
+	"subject_class_map": true,
+}
+
+// flattenPairs turns an object such as {"10A": 30, "10B": 25} into the
+// "10A:30,10B:25" form of a pairConfigKeys env var
+func flattenPairs(object string) (string, error) {
+	var pairs map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(object), &pairs); err != nil {
+		return "", fmt.Errorf("want an object such as {\"10A\": 30}: %v", err)
+	}
+	names := make([]string, 0, len(pairs))
+	for name := range pairs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := make([]string, len(names))
+	for i, name := range names {
+		var value string
+		if json.Unmarshal(pairs[name], &value) != nil {
+			value = string(bytes.TrimSpace(pairs[name]))
+		}
+		entries[i] = name + ":" + value
+	}
+	return strings.Join(entries, ","), nil
+}
+
+// parseJSONConfig flattens a JSON object into env var style text values
+func parseJSONConfig(data []byte) (map[string]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		var s string
+		var list []string
+		switch {
+		case json.Unmarshal(value, &s) == nil:
+			values[key] = s
+		case json.Unmarshal(value, &list) == nil:
+			values[key] = strings.Join(list, ",")
+		default:
+			// Numbers, booleans and objects read the same as their JSON text
+			values[key] = string(bytes.TrimSpace(value))
+		}
+	}
+	return values, nil
+}
+
+// parseYAMLConfig reads the flat subset of YAML a config file needs: one
+// "key: value" per line, with blank lines and # comments ignored and values
+// optionally quoted
+func parseYAMLConfig(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || line == "---" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("line %d: want key: value", n)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, `"`):
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: malformed quoted value", n)
+			}
+			value = unquoted
+		case strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") && len(value) > 1:
+			value = strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+		case strings.HasPrefix(value, "{"):
+			// Inline JSON objects are valid YAML; keep them whole
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	return values, scanner.Err()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// decodeErrorMessage turns a JSON decoding error into a precise, client-facing
+// message naming the offending field and byte offset where possible
+func decodeErrorMessage(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("Invalid request payload: malformed JSON at offset %d: %v", syntaxErr.Offset, syntaxErr)
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		return fmt.Sprintf("Invalid request payload: %s expected %s but got %s at offset %d",
+			field, jsonTypeName(typeErr.Type), typeErr.Value, typeErr.Offset)
+	case errors.Is(err, errSubjectAndSubjects):
+		return "Invalid request payload: " + err.Error()
+	case errors.Is(err, io.EOF):
+		return "Invalid request payload: body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "Invalid request payload: body ends unexpectedly"
+	default:
+		return "Invalid request payload"
+	}
+}
+
+// jsonTypeName describes a Go type in terms of the JSON type it decodes from
+func jsonTypeName(t reflect.Type) string {
+	if t == nil {
+		return "value"
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return t.String()
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// recentCreate is a create payload seen within DUPLICATE_WINDOW. A pending
+// one is still being written and has no expiry yet.
+type recentCreate struct {
+	id      string
+	pending bool
+	expires time.Time
+}
+
+// recentCreates remembers recent create payloads by content hash, so an
+// accidental double-submit can be answered with the first request's record
+var (
+	recentCreatesMu sync.Mutex
+	recentCreates   = make(map[string]recentCreate)
+)
+
+// payloadHash identifies a create payload by the fields it sets, so
+// formatting and key order in the original body make no difference
+func payloadHash(student Student) string {
+	normalized, _ := json.Marshal(struct {
+		EnrollmentNumber string            `json:"enrollment_number"`
+		Name             string            `json:"name"`
+		Age              int               `json:"age"`
+		DateOfBirth      string            `json:"date_of_birth"`
+		Class            string            `json:"class"`
+		Subjects         []string          `json:"subjects"`
+		Tags             map[string]string `json:"tags"`
+	}{student.EnrollmentNumber, student.Name, student.Age, student.DateOfBirth, student.Class, student.Subjects, student.Tags})
+	sum := sha256.Sum256(normalized)
+	return hex.EncodeToString(sum[:])
+}
+
+// claimPayload records hash as pending creation under id, unless an
+// identical payload is still being written or created a still-active student
+// within the window, in which case that create's enrollment number is
+// returned. The claim must be settled with confirmPayload or releasePayload.
+func claimPayload(hash, id string) (string, bool) {
+	recentCreatesMu.Lock()
+	defer recentCreatesMu.Unlock()
+
+	current := now()
+	for key, entry := range recentCreates {
+		if !entry.pending && !current.Before(entry.expires) {
+			delete(recentCreates, key)
+		}
+	}
+
+	if entry, ok := recentCreates[hash]; ok {
+		if entry.pending {
+			return entry.id, true
+		}
+		if existing, exists := store.Get(entry.id); exists && !existing.IsDeleted {
+			return entry.id, true
+		}
+	}
+	recentCreates[hash] = recentCreate{id: id, pending: true}
+	return "", false
+}
+
+// confirmPayload starts the window of a claim whose create was stored
+func confirmPayload(hash, id string) {
+	recentCreatesMu.Lock()
+	defer recentCreatesMu.Unlock()
+
+	if recentCreates[hash].id == id {
+		recentCreates[hash] = recentCreate{id: id, expires: now().Add(cfg.DuplicateWindow)}
+	}
+}
+
+// releasePayload forgets a claim whose create failed
+func releasePayload(hash, id string) {
+	recentCreatesMu.Lock()
+	defer recentCreatesMu.Unlock()
+
+	if recentCreates[hash].id == id {
+		delete(recentCreates, hash)
+	}
+}
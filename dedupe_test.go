@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConcurrentDuplicateCreates sends one payload many times at once and
+// checks exactly one of them creates a student
+func TestConcurrentDuplicateCreates(t *testing.T) {
+	srv := newTestServer(t, func(c *Config) { c.DuplicateWindow = time.Minute })
+	payload := `{"name":"Ann","age":10,"class":"5A"}`
+
+	const workers = 32
+	statuses := make(chan int, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, _ := doJSON(t, srv, http.MethodPost, "/student/v1/students", payload)
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[http.StatusOK] != 1 || counts[http.StatusConflict] != workers-1 {
+		t.Errorf("statuses = %v, want one 200 and %d 409s", counts, workers-1)
+	}
+	if got := len(store.Active()); got != 1 {
+		t.Errorf("%d students stored, want 1", got)
+	}
+}
+
+func TestPayloadHashCoversTags(t *testing.T) {
+	a := Student{Name: "Ann", Age: 10, Class: "5A", Tags: map[string]string{"team": "red"}}
+	b := a
+	b.Tags = map[string]string{"team": "blue"}
+	if payloadHash(a) == payloadHash(b) {
+		t.Error("payloads differing only in tags hash the same")
+	}
+}
+
+// TestPendingClaim checks a claim blocks identical creates while its write is
+// still in flight, before the student can be found in the store
+func TestPendingClaim(t *testing.T) {
+	newTestHandler(t, func(c *Config) { c.DuplicateWindow = time.Minute })
+	hash := payloadHash(Student{Name: "Ann", Age: 10, Class: "5A"})
+
+	if _, duplicate := claimPayload(hash, "A"); duplicate {
+		t.Fatal("first claim reported a duplicate")
+	}
+	if id, duplicate := claimPayload(hash, "B"); !duplicate || id != "A" {
+		t.Fatalf("claim while A is pending = %q, %t, want A, true", id, duplicate)
+	}
+
+	releasePayload(hash, "A")
+	if _, duplicate := claimPayload(hash, "B"); duplicate {
+		t.Fatal("claim after a release reported a duplicate")
+	}
+	store.Update("B", func(tx storeTx) error {
+		tx.Put(Student{EnrollmentNumber: "B", Name: "Ann", Age: 10, Class: "5A"})
+		return nil
+	})
+	confirmPayload(hash, "B")
+	if id, duplicate := claimPayload(hash, "C"); !duplicate || id != "B" {
+		t.Errorf("claim after B was stored = %q, %t, want B, true", id, duplicate)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+// Enrollment number generation modes selectable via ENROLLMENT_MODE
+const (
+	enrollmentModeUUID     = "uuid"
+	enrollmentModeSequence = "sequence"
+)
+
+// Limits on ENROLLMENT_PREFIX
+const maxEnrollmentPrefixLength = 10
+
+var enrollmentPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+// Last sequence number issued in sequence mode. It is included in backups and
+// mirrored to ENROLLMENT_SEQUENCE_FILE so numbering continues across restarts.
+var (
+	enrollmentSeq      atomic.Uint64
+	seqFileMu          sync.Mutex
+	seqFileLastWritten uint64
+)
+
+// validEnrollmentPrefix reports whether prefix is short and alphanumeric
+func validEnrollmentPrefix(prefix string) bool {
+	return len(prefix) <= maxEnrollmentPrefixLength && enrollmentPrefixPattern.MatchString(prefix)
+}
+
+// generateEnrollmentNumber returns a new server-assigned enrollment number:
+// a UUID, or the next zero-padded sequence number in sequence mode, with
+// ENROLLMENT_PREFIX prepended when set (e.g. "STU-000042")
+func generateEnrollmentNumber() string {
+	if cfg.EnrollmentMode == enrollmentModeSequence {
+		return formatSequenceNumber(nextSequence())
+	}
+	return prefixEnrollmentNumber(uuid.New().String())
+}
+
+// formatSequenceNumber lays out sequence number n as sequence mode issues it
+func formatSequenceNumber(n uint64) string {
+	return prefixEnrollmentNumber(fmt.Sprintf("%0*d", cfg.EnrollmentPadding, n))
+}
+
+func prefixEnrollmentNumber(id string) string {
+	if cfg.EnrollmentPrefix != "" {
+		return cfg.EnrollmentPrefix + "-" + id
+	}
+	return id
+}
+
+var errNoFreeEnrollmentNumber = errors.New("no free enrollment number found")
+
+// allocateEnrollmentNumber generates enrollment numbers until one is not
+// taken, giving up after ENROLLMENT_ID_ATTEMPTS tries. Collisions are all but
+// impossible for UUIDs, but a sequence can run into numbers clients chose
+// themselves or that a restored backup brought back.
+func allocateEnrollmentNumber(taken func(id string) bool) (string, error) {
+	for attempt := 1; attempt <= cfg.EnrollmentIDAttempts; attempt++ {
+		id := generateEnrollmentNumber()
+		if !taken(id) {
+			return id, nil
+		}
+		WarnLogger.Printf("Generated enrollment number %s is taken (attempt %d of %d)", id, attempt, cfg.EnrollmentIDAttempts)
+	}
+	return "", errNoFreeEnrollmentNumber
+}
+
+// previewEnrollmentNumbers returns count enrollment numbers as
+// allocateEnrollmentNumber would hand them out right now, without consuming
+// any: in sequence mode the counter is left alone. Nothing is reserved, so a
+// later create may still be given different numbers.
+func previewEnrollmentNumbers(count int, taken func(id string) bool) ([]string, error) {
+	ids := make([]string, 0, count)
+	seen := make(map[string]bool, count)
+	free := func(id string) bool {
+		return !seen[storeKey(id)] && !taken(id)
+	}
+
+	seq := enrollmentSeq.Load()
+	for len(ids) < count {
+		var id string
+		for attempt := 1; ; attempt++ {
+			if attempt > cfg.EnrollmentIDAttempts {
+				return nil, errNoFreeEnrollmentNumber
+			}
+			if cfg.EnrollmentMode == enrollmentModeSequence {
+				seq++
+				id = formatSequenceNumber(seq)
+			} else {
+				id = generateEnrollmentNumber()
+			}
+			if free(id) {
+				break
+			}
+		}
+		seen[storeKey(id)] = true
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// nextSequence atomically issues the next sequence number and persists it
+func nextSequence() uint64 {
+	n := enrollmentSeq.Add(1)
+	persistSequence(n)
+	return n
+}
+
+// advanceSequence moves the counter forward to at least n, never backwards
+func advanceSequence(n uint64) {
+	for {
+		current := enrollmentSeq.Load()
+		if n <= current || enrollmentSeq.CompareAndSwap(current, n) {
+			break
+		}
+	}
+	persistSequence(enrollmentSeq.Load())
+}
+
+// loadSequence resumes the counter from ENROLLMENT_SEQUENCE_FILE, if any
+func loadSequence() {
+	if cfg.EnrollmentSequenceFile == "" {
+		return
+	}
+
+	data, err := os.ReadFile(cfg.EnrollmentSequenceFile)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		ErrorLogger.Fatalf("Failed to read enrollment sequence file: %v", err)
+	}
+
+	n, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		ErrorLogger.Fatalf("Invalid enrollment sequence file %s: %v", cfg.EnrollmentSequenceFile, err)
+	}
+	advanceSequence(n)
+	InfoLogger.Printf("Resuming enrollment sequence after %d", n)
+}
+
+// persistSequence writes n to ENROLLMENT_SEQUENCE_FILE via a temp file and
+// rename, so a crash never leaves a truncated value. Concurrent callers may
+// finish out of order, so a lower number never overwrites a higher one.
+func persistSequence(n uint64) {
+	if cfg.EnrollmentSequenceFile == "" {
+		return
+	}
+
+	seqFileMu.Lock()
+	defer seqFileMu.Unlock()
+	if n <= seqFileLastWritten {
+		return
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(cfg.EnrollmentSequenceFile), ".enrollment-seq-*")
+	if err != nil {
+		ErrorLogger.Printf("Failed to persist enrollment sequence %d: %v", n, err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = fmt.Fprintf(tmp, "%d\n", n)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), cfg.EnrollmentSequenceFile)
+	}
+	if err != nil {
+		ErrorLogger.Printf("Failed to persist enrollment sequence %d: %v", n, err)
+		return
+	}
+	seqFileLastWritten = n
+}
+
+// Bytes probeWritable writes, so a nearly full disk fails the probe rather
+// than the next real write
+const writeProbeSize = 64 << 10
+
+// probeWritable writes, syncs and removes a scratch file in dir. Unlike
+// checking free space with statfs, this also catches read-only mounts,
+// permission changes and quota limits.
+func probeWritable(dir string) error {
+	tmp, err := os.CreateTemp(dir, ".health-probe-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(make([]byte, writeProbeSize))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
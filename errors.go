@@ -0,0 +1,78 @@
+package main
+
+import "net/http"
+
+// Stable machine-readable error codes returned in the "code" field of every
+// error response. Clients should branch on these, never on the message.
+const (
+	codeInvalidJSON          = "INVALID_JSON"
+	codeInvalidParameter     = "INVALID_PARAMETER"
+	codeInvalidPatch         = "INVALID_PATCH"
+	codeInvalidBackup        = "INVALID_BACKUP"
+	codeInvalidArchive       = "INVALID_ARCHIVE"
+	codeImportTooLarge       = "IMPORT_TOO_LARGE"
+	codeUnsupportedArchive   = "UNSUPPORTED_ARCHIVE_VERSION"
+	codeUnsupportedMediaType = "UNSUPPORTED_MEDIA_TYPE"
+	codeUnexpectedBody       = "UNEXPECTED_BODY"
+	codeValidationFailed     = "VALIDATION_FAILED"
+	codeStudentNotFound      = "STUDENT_NOT_FOUND"
+	codeSubjectNotFound      = "SUBJECT_NOT_FOUND"
+	codeTagNotFound          = "TAG_NOT_FOUND"
+	codeVersionNotFound      = "VERSION_NOT_FOUND"
+	codeDuplicateStudent     = "DUPLICATE_STUDENT"
+	codeDuplicateSubject     = "DUPLICATE_SUBJECT"
+	codeDuplicateRequest     = "DUPLICATE_REQUEST"
+	codeSameStudent          = "SAME_STUDENT"
+	codeAmbiguousLookup      = "AMBIGUOUS_LOOKUP"
+	codeClassFull            = "CLASS_FULL"
+	codePatchTestFailed      = "PATCH_TEST_FAILED"
+	codePreconditionFailed   = "PRECONDITION_FAILED"
+	codePreconditionRequired = "PRECONDITION_REQUIRED"
+	codeConfirmationRequired = "CONFIRMATION_REQUIRED"
+	codeUnauthorized         = "UNAUTHORIZED"
+	codeAdminDisabled        = "ADMIN_DISABLED"
+	codeQueryTooLarge        = "QUERY_TOO_LARGE"
+	codeServerBusy           = "SERVER_BUSY"
+	codeRequestTimeout       = "REQUEST_TIMEOUT"
+	codeRouteNotFound        = "ROUTE_NOT_FOUND"
+	codeMethodNotAllowed     = "METHOD_NOT_ALLOWED"
+	codeInternal             = "INTERNAL_ERROR"
+)
+
+// errorResponse is the JSON body of every error reply
+type errorResponse struct {
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+// Seconds clients are asked to wait before retrying a 429 or 503 whose
+// handler didn't set a more specific Retry-After
+var defaultRetryAfter = map[int]string{
+	http.StatusTooManyRequests:    "1",
+	http.StatusServiceUnavailable: "5",
+}
+
+// setRetryAfter gives 429 and 503 responses a Retry-After header unless the
+// handler already chose one, so every back-off reply tells clients when to
+// come back
+func setRetryAfter(w http.ResponseWriter, status int) {
+	if seconds, ok := defaultRetryAfter[status]; ok && w.Header().Get("Retry-After") == "" {
+		w.Header().Set("Retry-After", seconds)
+	}
+}
+
+// writeError replies with status and a JSON body carrying code and message
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	setRetryAfter(w, status)
+	writeJSON(w, status, errorResponse{Code: code, Error: message})
+}
+
+// routeNotFound and methodNotAllowed stand in for mux's plain-text defaults
+func routeNotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, codeRouteNotFound, "No such endpoint")
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+}
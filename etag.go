@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// computeETag returns a strong ETag derived from a response body
+func computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches implements the weak comparison If-None-Match requires: a W/
+// prefix on either side is ignored, and "*" matches any current
+// representation
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+var errPreconditionFailed = errors.New("if-match precondition failed")
+
+// ifMatchSatisfied implements If-Match's strong comparison: weak tags never
+// match, and "*" matches any current representation
+func ifMatchSatisfied(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || (!strings.HasPrefix(candidate, "W/") && candidate == etag) {
+			return true
+		}
+	}
+	return false
+}
+
+// studentETag is the ETag GET /students/{id} serves for student
+func studentETag(student Student) (string, error) {
+	body, err := encodeJSON(student)
+	if err != nil {
+		return "", err
+	}
+	return computeETag(body), nil
+}
+
+// setLastModified sets Last-Modified from modified and reports whether the
+// client's If-Modified-Since shows it already has that version. As RFC 9110
+// requires, If-Modified-Since is ignored when If-None-Match is present.
+func setLastModified(w http.ResponseWriter, r *http.Request, modified time.Time) bool {
+	if modified.IsZero() {
+		return false
+	}
+	// HTTP dates have one-second resolution
+	modified = modified.UTC().Truncate(time.Second)
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+
+	if r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !modified.After(since)
+}
+
+// writeJSONWithETag serializes v, tags it with an ETag and replies 304 when the
+// client's If-None-Match already names that representation
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, v interface{}) {
+	if requestCancelled(r) {
+		return
+	}
+
+	body, err := encodeJSON(v)
+	if err != nil {
+		ErrorLogger.Printf("Failed to encode response: %v", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Internal server error")
+		return
+	}
+	writeBodyWithETag(w, r, body)
+}
+
+// encodeJSON serializes v the way responses are written
+func encodeJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+// writeBodyWithETag writes an already encoded JSON body the way
+// writeJSONWithETag does
+func writeBodyWithETag(w http.ResponseWriter, r *http.Request, body []byte) {
+	etag := computeETag(body)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(body); err != nil {
+		ErrorLogger.Printf("Failed to write response: %v", err)
+	}
+}
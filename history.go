@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// studentVersion is a student as committed by one write
+type studentVersion struct {
+	Version    int       `json:"version"`
+	RecordedAt time.Time `json:"recorded_at"`
+	Deleted    bool      `json:"deleted"`
+	Student    Student   `json:"student"`
+}
+
+// studentHistory keeps the last HISTORY_LIMIT versions of every student,
+// keyed like the store. The store records into it as transactions commit,
+// so rolled-back writes never appear. Its lock is only ever taken after the
+// store's, never the other way round.
+type studentHistory struct {
+	mu       sync.Mutex
+	versions map[string][]studentVersion
+}
+
+var history = &studentHistory{versions: make(map[string][]studentVersion)}
+
+// record appends student as the next version of key, or forgets key once the
+// record is gone from the store
+func (h *studentHistory) record(key string, student Student, exists bool) {
+	if cfg.HistoryLimit == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !exists {
+		delete(h.versions, key)
+		return
+	}
+	versions := h.versions[key]
+	next := 1
+	if len(versions) > 0 {
+		next = versions[len(versions)-1].Version + 1
+	}
+	versions = append(versions, studentVersion{Version: next, RecordedAt: timestamp(), Deleted: student.IsDeleted, Student: student})
+	if len(versions) > cfg.HistoryLimit {
+		versions = versions[len(versions)-cfg.HistoryLimit:]
+	}
+	h.versions[key] = versions
+}
+
+// list returns a copy of the recorded versions of id, oldest first
+func (h *studentHistory) list(id string) []studentVersion {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]studentVersion(nil), h.versions[storeKey(id)]...)
+}
+
+// reset forgets every version, for when the store is replaced wholesale
+func (h *studentHistory) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.versions = make(map[string][]studentVersion)
+}
+
+// GET /student/v1/students/{studentId}/history - Recorded versions of a
+// student, oldest first
+func getStudentHistory(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["studentId"]
+
+	versions := history.list(id)
+	if len(versions) == 0 {
+		writeError(w, http.StatusNotFound, codeVersionNotFound, "No history for student")
+		return
+	}
+
+	InfoLogger.Printf("Retrieved history of student %s", id)
+	writeJSONWithETag(w, r, listResponse(r, versions))
+}
+
+// revertRequest selects a version by number or as the latest recorded at or
+// before a timestamp. Fields limits which editable fields are restored.
+type revertRequest struct {
+	Version   int        `json:"version"`
+	Timestamp *time.Time `json:"timestamp"`
+	Fields    []string   `json:"fields"`
+}
+
+// Editable fields a revert may restore
+var revertableFields = map[string]func(dst *Student, src Student){
+	"name":          func(dst *Student, src Student) { dst.Name = src.Name },
+	"age":           func(dst *Student, src Student) { dst.Age = src.Age },
+	"date_of_birth": func(dst *Student, src Student) { dst.DateOfBirth = src.DateOfBirth },
+	"class":         func(dst *Student, src Student) { dst.Class = src.Class },
+	"subjects":      func(dst *Student, src Student) { dst.Subjects = src.Subjects },
+}
+
+// find picks the version req selects from versions
+func (req revertRequest) find(versions []studentVersion) (studentVersion, bool) {
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		if (req.Version != 0 && v.Version == req.Version) ||
+			(req.Timestamp != nil && !v.RecordedAt.After(*req.Timestamp)) {
+			return v, true
+		}
+	}
+	return studentVersion{}, false
+}
+
+// POST /student/v1/students/{studentId}/revert - Restore editable fields from
+// a recorded version. The revert is itself a new version.
+func revertStudent(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["studentId"]
+
+	var req revertRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ErrorLogger.Printf("Failed to decode request body: %v", err)
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
+		return
+	}
+	if (req.Version == 0) == (req.Timestamp == nil) {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, "Exactly one of version or timestamp is required")
+		return
+	}
+	if len(req.Fields) == 0 {
+		req.Fields = []string{"name", "age", "date_of_birth", "class", "subjects"}
+	}
+	for _, field := range req.Fields {
+		if revertableFields[field] == nil {
+			writeError(w, http.StatusBadRequest, codeInvalidParameter, fmt.Sprintf("Field %q cannot be reverted", field))
+			return
+		}
+	}
+
+	target, found := req.find(history.list(id))
+	if !found {
+		writeError(w, http.StatusNotFound, codeVersionNotFound, "Version not found")
+		return
+	}
+
+	var student Student
+	err = writeUnique(id, func(tx storeTx) error {
+		existing, exists := tx.Get(id)
+		if !exists || existing.IsDeleted {
+			return errStudentNotFound
+		}
+
+		student = existing
+		for _, field := range req.Fields {
+			revertableFields[field](&student, target.Student)
+		}
+		if verr := validateStudent(student); verr != nil {
+			return verr
+		}
+		if err := checkUnique(tx, student, id); err != nil {
+			return err
+		}
+		if err := checkCapacity(tx, student, id); err != nil {
+			return err
+		}
+		student.UpdatedAt = timestamp()
+		tx.Put(student)
+		return nil
+	})
+
+	var verr *validationError
+	var full *classFullError
+	switch {
+	case errors.Is(err, errStudentNotFound):
+		writeError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	case errors.Is(err, errDuplicateStudent):
+		writeError(w, http.StatusConflict, codeDuplicateStudent, "Student already exists")
+		return
+	case errors.As(err, &full):
+		writeClassFull(w, full)
+		return
+	case errors.As(err, &verr):
+		writeValidationError(w, r, verr)
+		return
+	case err != nil:
+		ErrorLogger.Printf("Failed to revert student %s: %v", id, err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Internal server error")
+		return
+	}
+
+	InfoLogger.Printf("Reverted student %s to version %d", id, target.Version)
+	notifyWebhook(eventStudentUpdated, student)
+	writeJSON(w, http.StatusOK, student)
+}
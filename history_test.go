@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// failingStore reads like the store it wraps but refuses every write
+type failingStore struct {
+	*studentStore
+}
+
+var errStoreUnavailable = errors.New("store unavailable")
+
+func (f failingStore) Update(id string, fn func(tx storeTx) error) error {
+	return errStoreUnavailable
+}
+
+func (f failingStore) Exclusive(fn func(tx storeTx) error) error {
+	return errStoreUnavailable
+}
+
+func TestRevertReportsStoreFailure(t *testing.T) {
+	srv := newTestServer(t, nil)
+	id := mustCreate(t, srv, map[string]interface{}{"name": "Ann", "age": 10, "class": "5A"})
+	resp, body := doJSON(t, srv, http.MethodPut, "/student/v1/students/"+id, map[string]interface{}{"name": "Anna", "age": 10, "class": "5A"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT: status %d: %s", resp.StatusCode, body)
+	}
+
+	store = failingStore{store.(*studentStore)}
+	resp, body = doJSON(t, srv, http.MethodPost, "/student/v1/students/"+id+"/revert", `{"version":1}`)
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("revert with a failing store: status %d: %s, want 500", resp.StatusCode, body)
+	}
+}
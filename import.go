@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	csvContentType    = "text/csv"
+	ndjsonContentType = "application/x-ndjson"
+)
+
+// Kinds of event streamed by POST /student/v1/students/import
+const (
+	importEventProgress = "progress"
+	importEventError    = "error"
+	importEventDone     = "done"
+	importEventAborted  = "aborted"
+)
+
+// importEvent is one line of an import's response stream
+type importEvent struct {
+	Event     string `json:"event"`
+	Processed int    `json:"processed"`
+	Total     int    `json:"total,omitempty"`
+	Created   int    `json:"created"`
+	Failed    int    `json:"failed,omitempty"`
+	// Set on error events, and on aborted ones caused by a row
+	Index   *int   `json:"index,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// importRowError is a row that could be read but not turned into a student,
+// such as a CSV age that isn't a number. Unlike other read errors it doesn't
+// end the stream.
+type importRowError struct {
+	field fieldError
+}
+
+func (e *importRowError) Error() string {
+	return e.field.Field + ": " + e.field.Message
+}
+
+// importRows reads students one at a time from an upload, returning io.EOF
+// after the last
+type importRows func() (Student, error)
+
+// CSV columns the import understands, matched against the header row after
+// lowercasing and turning spaces into underscores, so a CSV export's own
+// header ("Enrollment Number", "Date of Birth", ...) reads back as is.
+// Other columns are ignored; subjects are comma-separated within their cell.
+var importColumns = map[string]func(*Student, string) error{
+	"enrollment_number": func(s *Student, v string) error { s.EnrollmentNumber = v; return nil },
+	"name":              func(s *Student, v string) error { s.Name = v; return nil },
+	"date_of_birth":     func(s *Student, v string) error { s.DateOfBirth = v; return nil },
+	"class":             func(s *Student, v string) error { s.Class = v; return nil },
+	"age": func(s *Student, v string) error {
+		if v == "" {
+			return nil
+		}
+		age, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return &importRowError{field: newFieldError("age", fieldNotInteger)}
+		}
+		s.Age = age
+		return nil
+	},
+	"subjects": func(s *Student, v string) error {
+		for _, subject := range strings.Split(v, ",") {
+			if subject = strings.TrimSpace(subject); subject != "" {
+				s.Subjects = append(s.Subjects, subject)
+			}
+		}
+		return nil
+	},
+}
+
+// newImportRows starts reading r's body as CSV when it is declared as
+// text/csv, and as a JSON array of students otherwise. Errors mean the
+// upload is unusable from the start.
+func newImportRows(r *http.Request) (importRows, error) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == csvContentType {
+		return csvImportRows(r.Body)
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, errors.New("Invalid request payload: body must be a JSON array of students")
+	}
+	return func() (Student, error) {
+		if !decoder.More() {
+			return Student{}, io.EOF
+		}
+		var student Student
+		if err := decoder.Decode(&student); err != nil {
+			return Student{}, errors.New(decodeErrorMessage(err))
+		}
+		return student, nil
+	}, nil
+}
+
+func csvImportRows(body io.Reader) (importRows, error) {
+	reader := csv.NewReader(body)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CSV: could not read the header row: %v", err)
+	}
+	columns := make([]func(*Student, string) error, len(header))
+	named := false
+	for i, name := range header {
+		key := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+		columns[i] = importColumns[key]
+		named = named || key == "name"
+	}
+	if !named {
+		return nil, errors.New("Invalid CSV: the header row must include a name column")
+	}
+
+	return func() (Student, error) {
+		record, err := reader.Read()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				err = fmt.Errorf("Invalid CSV: %v", err)
+			}
+			return Student{}, err
+		}
+		var student Student
+		for i, cell := range record {
+			if columns[i] != nil {
+				if err := columns[i](&student, cell); err != nil {
+					return Student{}, err
+				}
+			}
+		}
+		return student, nil
+	}, nil
+}
+
+// importStream writes an import's events as newline-delimited JSON, flushing
+// each so the client sees it right away
+type importStream struct {
+	flusher *http.ResponseController
+	encoder *json.Encoder
+	total   int
+}
+
+func (s *importStream) send(event importEvent) {
+	event.Total = s.total
+	if err := s.encoder.Encode(event); err != nil {
+		return
+	}
+	s.flusher.Flush()
+}
+
+// POST /student/v1/students/import[?mode=atomic|partial&total=N] - Create
+// students from a large JSON array or CSV upload, reading it row by row
+// instead of decoding it whole, and answering with a stream of
+// newline-delimited JSON events: progress every IMPORT_BATCH_SIZE rows, with
+// total echoed when the client gave it, then done, or aborted with the
+// reason the import stopped.
+//
+// Rows are created as bulk creates them. partial commits each batch as it
+// fills, streaming an error event for every row that fails. atomic (the
+// default) only stages and checks rows while the upload arrives and commits
+// them together at the end, so a failing row, a broken upload or a client
+// that disconnects leaves the store untouched. The staged rows are held in
+// memory, so atomic imports stop at IMPORT_MAX_ATOMIC_ROWS; partial ones
+// hold one batch at a time and take uploads of any size. Imports are exempt from
+// REQUEST_TIMEOUT, since a large upload can take any time to arrive.
+func importStudents(w http.ResponseWriter, r *http.Request) {
+	mode, err := parseBulkMode(r)
+	var total int
+	if err == nil {
+		total, err = parseNonNegative(r.URL.Query(), "total")
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	next, err := newImportRows(r)
+	if err != nil {
+		ErrorLogger.Printf("Failed to start import: %v", err)
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, err.Error())
+		return
+	}
+
+	// HTTP/1.1 servers close the request body once the response starts
+	// unless told otherwise, and events go out while rows still arrive
+	controller := http.NewResponseController(w)
+	if err := controller.EnableFullDuplex(); err != nil {
+		WarnLogger.Printf("Import response can't interleave with the upload: %v", err)
+	}
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	stream := &importStream{flusher: controller, encoder: json.NewEncoder(w), total: total}
+
+	var staged, batch []Student
+	processed, created, failed := 0, 0, 0
+	abort := func(code, message string, index *int) {
+		ErrorLogger.Printf("Aborted import after %d rows (mode %s, %d created): %s", processed, mode, created, message)
+		stream.send(importEvent{Event: importEventAborted, Processed: processed, Created: created, Failed: failed, Index: index, Code: code, Message: message})
+	}
+	// commit creates batch, the rows from first on, reporting whether the
+	// import may go on
+	commit := func(first int) bool {
+		var results []bulkItemResult
+		var rows []Student
+		err := store.Exclusive(func(tx storeTx) error {
+			var err error
+			results, rows, err = createRows(tx, batch, first, mode)
+			return err
+		})
+		var ierr *bulkItemError
+		if errors.As(err, &ierr) {
+			abort(ierr.code, ierr.Error(), &ierr.index)
+			return false
+		}
+		for _, result := range results {
+			if result.Status == bulkStatusError {
+				failed++
+				stream.send(importEvent{Event: importEventError, Processed: processed, Created: created, Index: &result.Index, Code: result.Code, Message: result.Message})
+			}
+		}
+		created += len(rows)
+		for _, student := range rows {
+			notifyWebhook(eventStudentCreated, student)
+		}
+		return true
+	}
+
+	for {
+		// Imports have no deadline, see streamingPaths, so only a client that
+		// went away ends the context, and there is no one left to tell
+		if err := requestErr(r); err != nil {
+			ErrorLogger.Printf("Abandoned import after %d rows (mode %s, %d created): %v", processed, mode, created, err)
+			return
+		}
+		student, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		index := processed
+		var rowErr *importRowError
+		switch {
+		case errors.As(err, &rowErr) && mode == bulkModePartial:
+			processed++
+			failed++
+			stream.send(importEvent{Event: importEventError, Processed: processed, Created: created, Index: &index, Code: codeValidationFailed, Message: rowErr.Error()})
+			continue
+		case rowErr != nil:
+			abort(codeValidationFailed, fmt.Sprintf("item %d: %s", index, rowErr.Error()), &index)
+			return
+		case err != nil:
+			abort(codeInvalidJSON, err.Error(), nil)
+			return
+		}
+
+		processed++
+		if mode == bulkModeAtomic {
+			if len(staged) == cfg.ImportMaxAtomicRows {
+				abort(codeImportTooLarge, fmt.Sprintf("Atomic imports are limited to %d rows; use mode=partial for larger uploads", cfg.ImportMaxAtomicRows), &index)
+				return
+			}
+			// Fail fast on rows that can never be created, rather than after
+			// the whole upload has arrived
+			if verr := validateNewStudent(inferClass(student)); verr != nil {
+				abort(codeValidationFailed, fmt.Sprintf("item %d: %s", index, verr.Error()), &index)
+				return
+			}
+			staged = append(staged, student)
+		} else {
+			batch = append(batch, student)
+			if len(batch) == cfg.ImportBatchSize {
+				if !commit(processed - len(batch)) {
+					return
+				}
+				batch = batch[:0]
+			}
+		}
+		if processed%cfg.ImportBatchSize == 0 {
+			stream.send(importEvent{Event: importEventProgress, Processed: processed, Created: created, Failed: failed})
+		}
+	}
+
+	if mode == bulkModeAtomic {
+		batch = staged
+	}
+	if len(batch) > 0 && !commit(processed-len(batch)) {
+		return
+	}
+	InfoLogger.Printf("Imported %d of %d students (mode %s)", created, processed, mode)
+	stream.send(importEvent{Event: importEventDone, Processed: processed, Created: created, Failed: failed})
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startImport posts body, which may still be arriving, to the import
+// endpoint and returns the response as it starts streaming
+func startImport(t *testing.T, srv *httptest.Server, query, contentType string, body io.Reader) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/student/v1/students/import"+query, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// readEvents decodes an import's event stream to the end
+func readEvents(t *testing.T, r io.Reader) []importEvent {
+	t.Helper()
+	var events []importEvent
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var event importEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("decoding event %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// TestImportOutlivesRequestTimeout sends an upload slowly enough to take
+// several times REQUEST_TIMEOUT and checks it still completes
+func TestImportOutlivesRequestTimeout(t *testing.T) {
+	srv := newTestServer(t, func(c *Config) {
+		c.RequestTimeout = 50 * time.Millisecond
+		c.MaxRequestTimeout = 50 * time.Millisecond
+	})
+
+	body, upload := io.Pipe()
+	go func() {
+		upload.Write([]byte("["))
+		for i := 0; i < 5; i++ {
+			if i > 0 {
+				upload.Write([]byte(","))
+			}
+			fmt.Fprintf(upload, `{"name":"Student %d","age":10,"class":"5A"}`, i)
+			time.Sleep(40 * time.Millisecond)
+		}
+		upload.Write([]byte("]"))
+		upload.Close()
+	}()
+
+	resp := startImport(t, srv, "", "application/json", body)
+	events := readEvents(t, resp.Body)
+	if len(events) == 0 {
+		t.Fatal("no events")
+	}
+	if last := events[len(events)-1]; last.Event != importEventDone || last.Created != 5 {
+		t.Fatalf("last event = %+v, want done with 5 created", last)
+	}
+	if got := len(store.Active()); got != 5 {
+		t.Errorf("%d students after the import, want 5", got)
+	}
+}
+
+func TestImportModes(t *testing.T) {
+	rows := `[{"name":"Ann","age":10,"class":"5A"},{"name":"","age":10},{"name":"Cat","age":12,"class":"5A"}]`
+	for _, tc := range []struct {
+		mode    string
+		last    string
+		created int
+	}{
+		{bulkModeAtomic, importEventAborted, 0},
+		{bulkModePartial, importEventDone, 2},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			srv := newTestServer(t, func(c *Config) { c.ImportBatchSize = 1 })
+			resp := startImport(t, srv, "?mode="+tc.mode, "application/json", strings.NewReader(rows))
+			if got := resp.Header.Get("Content-Type"); got != ndjsonContentType {
+				t.Errorf("Content-Type = %q, want %q", got, ndjsonContentType)
+			}
+			events := readEvents(t, resp.Body)
+			if last := events[len(events)-1]; last.Event != tc.last || last.Created != tc.created {
+				t.Errorf("last event = %+v, want %s with %d created", last, tc.last, tc.created)
+			}
+			if got := len(store.Active()); got != tc.created {
+				t.Errorf("%d students stored, want %d", got, tc.created)
+			}
+		})
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	srv := newTestServer(t, func(c *Config) { c.StrictContentType = true })
+	csv := "Name,Age,Class,Subjects\nAnn,10,5A,\"Math, Art\"\nBen,11,5B,\n"
+	resp := startImport(t, srv, "", csvContentType, strings.NewReader(csv))
+	events := readEvents(t, resp.Body)
+	if last := events[len(events)-1]; last.Event != importEventDone || last.Created != 2 {
+		t.Fatalf("last event = %+v, want done with 2 created", last)
+	}
+	for _, student := range store.Active() {
+		if student.Name == "Ann" && len(student.Subjects) != 2 {
+			t.Errorf("Ann's subjects = %q, want Math and Art", student.Subjects)
+		}
+	}
+}
+
+func TestImportAtomicRowLimit(t *testing.T) {
+	rows := `[{"name":"Ann","age":10,"class":"5A"},{"name":"Ben","age":11,"class":"5A"},{"name":"Cat","age":12,"class":"5A"}]`
+	srv := newTestServer(t, func(c *Config) { c.ImportMaxAtomicRows = 2 })
+
+	events := readEvents(t, startImport(t, srv, "", "application/json", strings.NewReader(rows)).Body)
+	if last := events[len(events)-1]; last.Event != importEventAborted || last.Code != codeImportTooLarge {
+		t.Errorf("atomic import over the limit ended with %+v, want aborted %s", last, codeImportTooLarge)
+	}
+	if got := len(store.Active()); got != 0 {
+		t.Errorf("%d students after an aborted import, want 0", got)
+	}
+
+	events = readEvents(t, startImport(t, srv, "?mode=partial", "application/json", strings.NewReader(rows)).Body)
+	if last := events[len(events)-1]; last.Event != importEventDone || last.Created != 3 {
+		t.Errorf("partial import ended with %+v, want done with 3 created", last)
+	}
+}
+
+// TestImportStreamsUnderCamelCase checks progress events reach the client
+// while the upload is still open, even through camelCaseMiddleware
+func TestImportStreamsUnderCamelCase(t *testing.T) {
+	for _, naming := range []string{namingSnake, namingCamel} {
+		t.Run(naming, func(t *testing.T) {
+			srv := newTestServer(t, func(c *Config) {
+				c.JSONNaming = naming
+				c.ImportBatchSize = 1
+			})
+
+			body, upload := io.Pipe()
+			defer upload.Close()
+			go upload.Write([]byte(`[{"name":"Ann","age":10,"class":"5A"}`))
+
+			// A buffered response holds back even the headers, so the whole
+			// exchange runs under the timeout below
+			lines := make(chan string, 1)
+			go func() {
+				resp, err := srv.Client().Post(srv.URL+"/student/v1/students/import", "application/json", body)
+				if err != nil {
+					lines <- err.Error()
+					return
+				}
+				defer resp.Body.Close()
+				line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+				lines <- line
+			}()
+			select {
+			case line := <-lines:
+				var event importEvent
+				if err := json.Unmarshal([]byte(line), &event); err != nil || event.Event != importEventProgress || event.Processed != 1 {
+					t.Errorf("first event = %q, want progress after 1 row", line)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("no event arrived while the upload was still open")
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// dataFinding is one stored record that fails current validation or could
+// be repaired
+type dataFinding struct {
+	EnrollmentNumber string       `json:"enrollment_number"`
+	Issues           []fieldError `json:"issues,omitempty"`
+	Repairs          []dataRepair `json:"repairs,omitempty"`
+}
+
+// dataRepair is a fix to one field, applied with repair=true and only
+// proposed otherwise
+type dataRepair struct {
+	Field string `json:"field"`
+	Fix   string `json:"fix"`
+	From  string `json:"from"`
+	To    string `json:"to,omitempty"`
+}
+
+// Kinds of dataRepair
+const (
+	fixTrimWhitespace  = "trim_whitespace"
+	fixNormalizeCase   = "normalize_case"
+	fixRemoveDuplicate = "remove_duplicate"
+)
+
+// duplicateKey is a natural key, see SYNC_KEY, shared by several active
+// students
+type duplicateKey struct {
+	Key               map[string]string `json:"key"`
+	EnrollmentNumbers []string          `json:"enrollment_numbers"`
+}
+
+// dataReport is the reply of POST /admin/validate-data
+type dataReport struct {
+	Scanned    int            `json:"scanned"`
+	Invalid    int            `json:"invalid"`
+	Repaired   int            `json:"repaired"`
+	Findings   []dataFinding  `json:"findings"`
+	Duplicates []duplicateKey `json:"duplicates"`
+}
+
+// POST /admin/validate-data[?repair=true] - Check every stored record,
+// soft-deleted ones included, against validateStudent, and active students
+// for natural keys they share. Whitespace around names, classes and subjects,
+// repeated subjects and classes spelled in a different case than the rest of
+// their class are repairable; repair=true fixes those in place, and the
+// issues reported are the ones left afterwards. Admin only.
+func validateData(w http.ResponseWriter, r *http.Request) {
+	repair, err := parseBool(r.URL.Query(), "repair")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	var report dataReport
+	var repaired []Student
+	scan := func(tx storeTx) error {
+		var records []Student
+		tx.Range(func(student Student) bool {
+			records = append(records, student)
+			return true
+		})
+		sort.Slice(records, func(i, j int) bool {
+			return records[i].EnrollmentNumber < records[j].EnrollmentNumber
+		})
+		report, repaired = checkRecords(records)
+		if repair {
+			for _, student := range repaired {
+				student.UpdatedAt = timestamp()
+				tx.Put(student)
+			}
+		}
+		return nil
+	}
+	if repair {
+		store.Exclusive(scan)
+	} else {
+		store.View(func(tx storeTx) { scan(tx) })
+	}
+
+	if repair {
+		report.Repaired = len(repaired)
+		for _, student := range repaired {
+			if !student.IsDeleted {
+				notifyWebhook(eventStudentUpdated, student)
+			}
+		}
+	}
+	lang := requestLanguage(r)
+	for i := range report.Findings {
+		report.Findings[i].Issues = localize(report.Findings[i].Issues, lang)
+	}
+	if report.Findings == nil {
+		report.Findings = []dataFinding{}
+	}
+	if report.Duplicates == nil {
+		report.Duplicates = []duplicateKey{}
+	}
+
+	InfoLogger.Printf("Validated %d stored students: %d invalid, %d repairable, %d duplicate keys, repair=%t",
+		report.Scanned, report.Invalid, len(repaired), len(report.Duplicates), repair)
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+	writeJSON(w, http.StatusOK, report)
+}
+
+// checkRecords validates records, sorted by enrollment number, as they would
+// be once repaired, returning the report and the repaired versions of the
+// records that changed
+func checkRecords(records []Student) (dataReport, []Student) {
+	report := dataReport{Scanned: len(records)}
+	classes := classSpellings(records)
+	var repaired []Student
+	active := make(map[string][]string)
+	var keys []string
+	for _, student := range records {
+		fixed, repairs := repairStudent(student, classes)
+		finding := dataFinding{EnrollmentNumber: student.EnrollmentNumber, Repairs: repairs}
+		if verr := validateStudent(fixed); verr != nil {
+			finding.Issues = verr.Fields
+			report.Invalid++
+		}
+		if len(finding.Issues) > 0 || len(repairs) > 0 {
+			report.Findings = append(report.Findings, finding)
+		}
+		if len(repairs) > 0 {
+			repaired = append(repaired, fixed)
+		}
+
+		if !fixed.IsDeleted {
+			key := naturalKey(fixed)
+			if _, seen := active[key]; !seen {
+				keys = append(keys, key)
+			}
+			active[key] = append(active[key], fixed.EnrollmentNumber)
+		}
+	}
+
+	for _, key := range keys {
+		if ids := active[key]; len(ids) > 1 {
+			parts := strings.Split(key, "\x00")
+			named := make(map[string]string, len(parts))
+			for i, field := range cfg.SyncKey {
+				named[field] = parts[i]
+			}
+			report.Duplicates = append(report.Duplicates, duplicateKey{Key: named, EnrollmentNumbers: ids})
+		}
+	}
+	return report, repaired
+}
+
+// classSpellings picks the canonical spelling of every class among records,
+// keyed by its lower case: the ALLOWED_CLASSES entry when there is one,
+// otherwise the spelling most records use, ties going to the one sorting
+// first
+func classSpellings(records []Student) map[string]string {
+	counts := make(map[string]map[string]int)
+	for _, student := range records {
+		class := strings.TrimSpace(student.Class)
+		lower := strings.ToLower(class)
+		if counts[lower] == nil {
+			counts[lower] = make(map[string]int)
+		}
+		counts[lower][class]++
+	}
+
+	spellings := make(map[string]string, len(counts))
+	for lower, byCase := range counts {
+		best := ""
+		for spelling, n := range byCase {
+			if best == "" || n > byCase[best] || n == byCase[best] && spelling < best {
+				best = spelling
+			}
+		}
+		spellings[lower] = best
+	}
+	for _, allowed := range cfg.AllowedClasses {
+		if _, ok := spellings[strings.ToLower(allowed)]; ok {
+			spellings[strings.ToLower(allowed)] = allowed
+		}
+	}
+	return spellings
+}
+
+// repairStudent returns student with its repairable problems fixed, and the
+// repairs that took. The subject list is copied before it is changed.
+func repairStudent(student Student, classes map[string]string) (Student, []dataRepair) {
+	var repairs []dataRepair
+	fix := func(field, kind, from, to string) string {
+		if from != to {
+			repairs = append(repairs, dataRepair{Field: field, Fix: kind, From: from, To: to})
+		}
+		return to
+	}
+
+	student.Name = fix("name", fixTrimWhitespace, student.Name, strings.TrimSpace(student.Name))
+	student.Class = fix("class", fixTrimWhitespace, student.Class, strings.TrimSpace(student.Class))
+	if canonical, ok := classes[strings.ToLower(student.Class)]; ok {
+		student.Class = fix("class", fixNormalizeCase, student.Class, canonical)
+	}
+
+	changed := len(repairs)
+	var subjects []string
+	for i, subject := range student.Subjects {
+		field := fmt.Sprintf("subjects[%d]", i)
+		trimmed := fix(field, fixTrimWhitespace, subject, strings.TrimSpace(subject))
+		if hasSubject(subjects, trimmed) {
+			repairs = append(repairs, dataRepair{Field: field, Fix: fixRemoveDuplicate, From: subject})
+			continue
+		}
+		subjects = append(subjects, trimmed)
+	}
+	if len(repairs) > changed {
+		student.Subjects = subjects
+	}
+	return student, repairs
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Most list responses LIST_CACHE_TTL keeps at once
+const maxListCacheEntries = 128
+
+// cachedList is an encoded list response and the store generation it was
+// built from
+type cachedList struct {
+	body      []byte
+	truncated bool
+	gen       uint64
+	expires   time.Time
+}
+
+// listCache holds recent list responses keyed by their normalized query.
+// Entries die after LIST_CACHE_TTL or as soon as the store is written to,
+// whichever comes first.
+type listCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedList
+}
+
+var responseCache = &listCache{entries: make(map[string]cachedList)}
+
+// listCacheKey identifies everything that shapes a list response: the query
+// with its parameters sorted, whether it is enveloped and its X-Page-Size
+func listCacheKey(r *http.Request) string {
+	return strconv.FormatBool(wantsEnvelope(r)) + "/" + r.Header.Get(pageSizeHeader) + "?" + r.URL.Query().Encode()
+}
+
+// get returns the entry for key if it is still current
+func (c *listCache) get(key string) (cachedList, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return cachedList{}, false
+	}
+	if entry.gen != store.Generation() || !now().Before(entry.expires) {
+		delete(c.entries, key)
+		return cachedList{}, false
+	}
+	return entry, true
+}
+
+// put stores entry under key, making room by dropping stale entries first
+// and then arbitrary ones
+func (c *listCache) put(key string, entry cachedList) {
+	entry.expires = now().Add(cfg.ListCacheTTL)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= maxListCacheEntries {
+		gen := store.Generation()
+		for k, e := range c.entries {
+			if e.gen != gen || !now().Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	for k := range c.entries {
+		if len(c.entries) < maxListCacheEntries {
+			break
+		}
+		delete(c.entries, k)
+	}
+	c.entries[key] = entry
+}
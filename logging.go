@@ -0,0 +1,45 @@
+package main
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// statusRecorder remembers the status a handler replied with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// requestLogMiddleware writes one access log line per request. Requests
+// slower than SLOW_REQUEST_MS go to WarnLogger instead, so pathological
+// ones stand out without turning on more logging. Other successful requests
+// are logged with probability LOG_SAMPLE_RATE; 4xx and 5xx always are.
+func requestLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		elapsed := time.Since(start)
+
+		if cfg.SlowRequestThreshold > 0 && elapsed > cfg.SlowRequestThreshold {
+			WarnLogger.Printf("Slow request: %s %s %d took %v (threshold %v)", r.Method, r.URL.Path, rec.status, elapsed, cfg.SlowRequestThreshold)
+			return
+		}
+		if rec.status < http.StatusBadRequest && cfg.LogSampleRate < 1 && rand.Float64() >= cfg.LogSampleRate {
+			return
+		}
+		InfoLogger.Printf("%s %s %d %v", r.Method, r.URL.Path, rec.status, elapsed)
+	})
+}
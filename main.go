@@ -1,135 +1,761 @@
-package main
-
-import (
-	"encoding/json"
-	"log"
-	"net/http"
-	"os"
-	"sync"
-
-	"github.com/google/uuid"
-	"github.com/gorilla/mux"
-)
-
-// Student struct defines the structure for student records
-type Student struct {
-	EnrollmentNumber string `json:"enrollment_number"`
-	Name             string `json:"name"`
-	Age              int    `json:"age"`
-	Class            string `json:"class"`
-	Subject          string `json:"subject"`
-	IsDeleted        bool   `json:"-"`
-}
-
-// In-memory database
-var students = make(map[string]Student)
-var mu sync.Mutex
-
-// Logger setup
-var (
-	InfoLogger  *log.Logger
-	ErrorLogger *log.Logger
-)
-
-func init() {
-	// Create log file
-	file, err := os.OpenFile("student-api.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		log.Fatalf("Failed to open log file: %v", err)
-	}
-
-	// Initialize loggers
-	InfoLogger = log.New(file, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-	ErrorLogger = log.New(file, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
-}
-
-// POST /student/v1/students - Create a new student
-func createStudent(w http.ResponseWriter, r *http.Request) {
-	var student Student
-	err := json.NewDecoder(r.Body).Decode(&student)
-	if err != nil {
-		ErrorLogger.Printf("Failed to decode request body: %v", err)
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
-		return
-	}
-
-	student.EnrollmentNumber = uuid.New().String()
-	mu.Lock()
-	students[student.EnrollmentNumber] = student
-	mu.Unlock()
-
-	InfoLogger.Printf("Created student: %v", student)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"enrollment_number": student.EnrollmentNumber})
-}
-
-// GET /student/v1/students/{studentId} - Get a single student by ID
-func getStudent(w http.ResponseWriter, r *http.Request) {
-	params := mux.Vars(r)
-	id := params["studentId"]
-
-	mu.Lock()
-	student, exists := students[id]
-	mu.Unlock()
-
-	if !exists || student.IsDeleted {
-		http.Error(w, "Student not found", http.StatusNotFound)
-		return
-	}
-
-	InfoLogger.Printf("Retrieved student: %v", student)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(student)
-}
-
-// GET /student/v1/students - Get all students
-func getAllStudents(w http.ResponseWriter, r *http.Request) {
-	mu.Lock()
-	defer mu.Unlock()
-
-	var result []Student
-	for _, student := range students {
-		if !student.IsDeleted {
-			result = append(result, student)
-		}
-	}
-
-	InfoLogger.Printf("Retrieved all students")
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
-}
-
-// DELETE /student/v1/students/{studentId} - Soft delete a student by ID
-func deleteStudent(w http.ResponseWriter, r *http.Request) {
-	params := mux.Vars(r)
-	id := params["studentId"]
-
-	mu.Lock()
-	student, exists := students[id]
-	if exists {
-		student.IsDeleted = true
-		students[id] = student
-	}
-	mu.Unlock()
-
-	if !exists {
-		http.Error(w, "Student not found", http.StatusNotFound)
-		return
-	}
-
-	InfoLogger.Printf("Deleted student: %v", student)
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func main() {
-	r := mux.NewRouter()
-	r.HandleFunc("/student/v1/students", createStudent).Methods("POST")
-	r.HandleFunc("/student/v1/students", getAllStudents).Methods("GET")
-	r.HandleFunc("/student/v1/students/{studentId}", getStudent).Methods("GET")
-	r.HandleFunc("/student/v1/students/{studentId}", deleteStudent).Methods("DELETE")
-
-	InfoLogger.Println("Starting server on port 8080")
-	if err := http.ListenAndServe(":8080", r); err != nil {
-		ErrorLogger.Fatalf("Failed to start server: %v", err)
-	}
-}
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Student struct defines the structure for student records
+type Student struct {
+	EnrollmentNumber string `json:"enrollment_number"`
+	Name             string `json:"name"`
+	Age              int    `json:"age"`
+	// Optional YYYY-MM-DD; when set, the age is derived from it on every
+	// read and any stored Age is ignored, see currentAge
+	DateOfBirth string   `json:"date_of_birth,omitempty"`
+	Class       string   `json:"class"`
+	Subjects    []string `json:"subjects"`
+	// Free-form key-value metadata for integrations, see checkTags
+	Tags      map[string]string `json:"tags,omitempty"`
+	IsDeleted bool              `json:"-"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Audit details for soft-deleted records
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy    string     `json:"deleted_by,omitempty"`
+	DeleteReason string     `json:"delete_reason,omitempty"`
+}
+
+// Logger setup
+var (
+	DebugLogger *log.Logger
+	InfoLogger  *log.Logger
+	WarnLogger  *log.Logger
+	ErrorLogger *log.Logger
+)
+
+func init() {
+	// Create log file
+	file, err := os.OpenFile("student-api.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		log.Fatalf("Failed to open log file: %v", err)
+	}
+
+	// Initialize loggers
+	DebugLogger = log.New(file, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	InfoLogger = log.New(file, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
+	WarnLogger = log.New(file, "WARN: ", log.Ldate|log.Ltime|log.Lshortfile)
+	ErrorLogger = log.New(file, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+}
+
+// POST /student/v1/students - Create a new student
+//
+// Write handlers answer malformed JSON with 400 and well-formed input that
+// fails validateNewStudent with 422.
+func createStudent(w http.ResponseWriter, r *http.Request) {
+	var student Student
+	err := json.NewDecoder(r.Body).Decode(&student)
+	if err != nil {
+		ErrorLogger.Printf("Failed to decode request body: %v", err)
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
+		return
+	}
+
+	student = withoutDeleteAudit(inferClass(student))
+	if verr := validateNewStudent(student); verr != nil {
+		writeValidationError(w, r, verr)
+		return
+	}
+
+	// Hash before an enrollment number is generated, so repeats of a payload
+	// without one still match
+	var hash string
+	if cfg.DuplicateWindow > 0 {
+		hash = payloadHash(student)
+	}
+
+	// Clients may supply their own enrollment number, otherwise generate a
+	// free one. A create racing for the same number still fails checkUnique.
+	if student.EnrollmentNumber == "" {
+		student.EnrollmentNumber, err = allocateEnrollmentNumber(func(id string) bool {
+			_, exists := store.Get(id)
+			return exists
+		})
+		if err != nil {
+			ErrorLogger.Printf("Failed to create student: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Could not allocate an enrollment number")
+			return
+		}
+	}
+	student.CreatedAt = timestamp()
+	student.UpdatedAt = student.CreatedAt
+
+	if hash != "" {
+		if id, duplicate := claimPayload(hash, student.EnrollmentNumber); duplicate {
+			InfoLogger.Printf("Rejected duplicate create of student %s", id)
+			writeJSON(w, http.StatusConflict, map[string]string{
+				"code":              codeDuplicateRequest,
+				"error":             "An identical student was just created",
+				"enrollment_number": id,
+			})
+			return
+		}
+	}
+
+	err = writeUnique(student.EnrollmentNumber, func(tx storeTx) error {
+		if err := checkUnique(tx, student, ""); err != nil {
+			return err
+		}
+		if err := checkCapacity(tx, student, ""); err != nil {
+			return err
+		}
+		tx.Put(student)
+		return nil
+	})
+	if err != nil {
+		if hash != "" {
+			releasePayload(hash, student.EnrollmentNumber)
+		}
+		var full *classFullError
+		if errors.As(err, &full) {
+			writeClassFull(w, full)
+			return
+		}
+		writeError(w, http.StatusConflict, codeDuplicateStudent, "Student already exists")
+		return
+	}
+	if hash != "" {
+		confirmPayload(hash, student.EnrollmentNumber)
+	}
+
+	InfoLogger.Printf("Created student: %v", student)
+	notifyWebhook(eventStudentCreated, student)
+	writeJSON(w, http.StatusOK, map[string]string{"enrollment_number": student.EnrollmentNumber})
+}
+
+// GET /student/v1/students/{studentId} - Get a single student by ID
+func getStudent(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["studentId"]
+
+	student, exists := store.Get(id)
+	if !exists || student.IsDeleted {
+		writeError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	}
+
+	if setLastModified(w, r, student.UpdatedAt) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	InfoLogger.Printf("Retrieved student: %v", student)
+	writeJSONWithETag(w, r, student)
+}
+
+// GET /student/v1/students/random - Get one random student
+//
+// Uses reservoir sampling, so it is a single O(n) scan under the lock but
+// never copies the store. math/rand/v2 is seeded randomly at startup.
+func getRandomStudent(w http.ResponseWriter, r *http.Request) {
+	var picked Student
+	seen := 0
+	ok := scanStudents(r, func(student Student) {
+		if student.IsDeleted {
+			return
+		}
+		seen++
+		if rand.IntN(seen) == 0 {
+			picked = student
+		}
+	})
+	if !ok {
+		return
+	}
+
+	if seen == 0 {
+		writeError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	}
+
+	InfoLogger.Printf("Retrieved random student: %v", picked)
+	writeJSON(w, http.StatusOK, picked)
+}
+
+// GET /student/v1/students - Get all students
+//
+// Optional query parameters:
+//   - q: case-insensitive substring matched against name, class OR subject
+//   - class, subject: exact matches, ANDed with each other and with q
+//   - no_subject: only students taking no subjects; 400 alongside subject
+//   - tag: key:value, or a bare key for any value; may repeat, all must match
+//   - limit, offset: pagination over results in DEFAULT_SORT order, by
+//     enrollment number unless configured otherwise. Without limit, an
+//     X-Page-Size header gives the page size instead.
+//   - include_deleted: also return soft-deleted students with their audit fields
+//   - modified_since: only students updated at or after an RFC 3339 time,
+//     including ones soft-deleted since, which carry deleted_at
+//
+// No response ever holds more than MAX_LIST_RESULTS records; when more match,
+// X-Result-Truncated is set and clients should paginate.
+func getAllStudents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", pageSizeHeader)
+	query, err := parseListQuery(r.URL.Query())
+	if err == nil {
+		err = applyPageSize(r, &query)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	var cacheKey string
+	var gen uint64
+	if cfg.ListCacheTTL > 0 {
+		cacheKey = listCacheKey(r)
+		if cached, ok := responseCache.get(cacheKey); ok {
+			w.Header().Set("X-Cache", "HIT")
+			if cached.truncated {
+				w.Header().Set("X-Result-Truncated", "true")
+			}
+			writeBodyWithETag(w, r, cached.body)
+			return
+		}
+		w.Header().Set("X-Cache", "MISS")
+		// Read before building, so a racing write marks the entry stale
+		gen = store.Generation()
+	}
+
+	var result []Student
+	if cfg.ListSnapshot && !query.filtered() {
+		result = store.Active()
+	} else {
+		ok := scanStudents(r, func(student Student) {
+			if query.includes(student) && query.matches(student) {
+				result = append(result, student)
+			}
+		})
+		if !ok {
+			return
+		}
+		sortStudents(result)
+	}
+	result = query.paginate(result)
+
+	truncated := cfg.MaxListResults > 0 && len(result) > cfg.MaxListResults
+	if truncated {
+		result = result[:cfg.MaxListResults]
+		w.Header().Set("X-Result-Truncated", "true")
+	}
+
+	// Results are sorted above, so the ETag is stable while the data is
+	InfoLogger.Printf("Retrieved all students")
+	if cacheKey == "" {
+		writeJSONWithETag(w, r, listResponse(r, result))
+		return
+	}
+	if requestCancelled(r) {
+		return
+	}
+	body, err := encodeJSON(listResponse(r, result))
+	if err != nil {
+		ErrorLogger.Printf("Failed to encode response: %v", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Internal server error")
+		return
+	}
+	responseCache.put(cacheKey, cachedList{body: body, truncated: truncated, gen: gen})
+	writeBodyWithETag(w, r, body)
+}
+
+// HEAD /student/v1/students - Count the students GET would match
+//
+// Accepts the same filters as GET and answers with just X-Total-Count, the
+// number of matches before limit, offset and MAX_LIST_RESULTS, so clients can
+// poll the total without transferring any records.
+func countStudents(w http.ResponseWriter, r *http.Request) {
+	query, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	total := 0
+	if !query.filtered() {
+		total = len(store.Active())
+	} else {
+		ok := scanStudents(r, func(student Student) {
+			if query.includes(student) && query.matches(student) {
+				total++
+			}
+		})
+		if !ok {
+			return
+		}
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	w.WriteHeader(http.StatusOK)
+}
+
+// GET /student/v1/students/by-class - Get students grouped by class
+//
+// Classes are JSON object keys and so serialize in sorted order; students
+// within a class are ordered by enrollment number. Supports include_deleted.
+func getStudentsByClass(w http.ResponseWriter, r *http.Request) {
+	includeDeleted, err := parseBool(r.URL.Query(), "include_deleted")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	groups := make(map[string][]Student)
+	ok := scanStudents(r, func(student Student) {
+		if !student.IsDeleted || includeDeleted {
+			groups[student.Class] = append(groups[student.Class], student)
+		}
+	})
+	if !ok {
+		return
+	}
+
+	for _, group := range groups {
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].EnrollmentNumber < group[j].EnrollmentNumber
+		})
+	}
+
+	InfoLogger.Printf("Retrieved students grouped by class")
+	markDataKeyed(w)
+	writeJSONWithETag(w, r, groups)
+}
+
+// GET /student/v1/students/ids - Get the sorted enrollment numbers of every
+// student, for clients diffing against a local cache. Supports include_deleted.
+func getStudentIDs(w http.ResponseWriter, r *http.Request) {
+	includeDeleted, err := parseBool(r.URL.Query(), "include_deleted")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	ids := []string{}
+	ok := scanStudents(r, func(student Student) {
+		if !student.IsDeleted || includeDeleted {
+			ids = append(ids, student.EnrollmentNumber)
+		}
+	})
+	if !ok {
+		return
+	}
+	sort.Strings(ids)
+
+	InfoLogger.Printf("Retrieved %d student ids", len(ids))
+	writeJSONWithETag(w, r, ids)
+}
+
+// PUT /student/v1/students/{studentId} - Replace a student's details
+func updateStudent(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["studentId"]
+
+	var student Student
+	err := json.NewDecoder(r.Body).Decode(&student)
+	if err != nil {
+		ErrorLogger.Printf("Failed to decode request body: %v", err)
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
+		return
+	}
+	student.EnrollmentNumber = id
+	student = withoutDeleteAudit(student)
+
+	if verr := validateStudent(student); verr != nil {
+		writeValidationError(w, r, verr)
+		return
+	}
+
+	err = writeUnique(id, func(tx storeTx) error {
+		existing, exists := tx.Get(id)
+		if !exists || existing.IsDeleted {
+			return errStudentNotFound
+		}
+		if err := checkUnique(tx, student, id); err != nil {
+			return err
+		}
+		if err := checkCapacity(tx, student, id); err != nil {
+			return err
+		}
+		student.EnrollmentNumber = existing.EnrollmentNumber
+		student.CreatedAt = existing.CreatedAt
+		student.UpdatedAt = timestamp()
+		tx.Put(student)
+		return nil
+	})
+	var full *classFullError
+	if errors.Is(err, errStudentNotFound) {
+		writeError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	}
+	if errors.As(err, &full) {
+		writeClassFull(w, full)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusConflict, codeDuplicateStudent, "Student already exists")
+		return
+	}
+
+	InfoLogger.Printf("Updated student: %v", student)
+	notifyWebhook(eventStudentUpdated, student)
+	writeJSON(w, http.StatusOK, student)
+}
+
+// PATCH /student/v1/students/{studentId} - Partially update a student
+//
+// Accepts an RFC 7396 merge patch (application/merge-patch+json or plain
+// application/json) or RFC 6902 operations (application/json-patch+json,
+// limited to replace, remove and test). A failed test operation returns 409.
+// Alternatively, with no body, the fields in queryPatchFields may be given as
+// query parameters.
+func patchStudent(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["studentId"]
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		ErrorLogger.Printf("Failed to read request body: %v", err)
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid request payload")
+		return
+	}
+
+	var apply func(doc map[string]interface{}) error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case r.URL.RawQuery != "":
+		if len(body) > 0 {
+			writeError(w, http.StatusBadRequest, codeInvalidPatch, "Send changes as query parameters or a body, not both")
+			return
+		}
+		patch, err := parseQueryPatch(r.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+			return
+		}
+		apply = func(doc map[string]interface{}) error {
+			applyMergePatch(doc, patch)
+			return nil
+		}
+	case mediaType == "application/json-patch+json":
+		ops, err := parseJSONPatch(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidPatch, err.Error())
+			return
+		}
+		apply = func(doc map[string]interface{}) error {
+			return applyJSONPatch(doc, ops)
+		}
+	case mediaType == "" || mediaType == "application/json" || mediaType == "application/merge-patch+json":
+		patch, err := parseMergePatch(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidPatch, err.Error())
+			return
+		}
+		apply = func(doc map[string]interface{}) error {
+			applyMergePatch(doc, patch)
+			return nil
+		}
+	default:
+		writeError(w, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "Unsupported patch content type")
+		return
+	}
+
+	var student Student
+	err = writeUnique(id, func(tx storeTx) error {
+		existing, exists := tx.Get(id)
+		if !exists || existing.IsDeleted {
+			return errStudentNotFound
+		}
+
+		var err error
+		if student, err = applyPatch(existing, apply); err != nil {
+			return err
+		}
+		if verr := validateStudent(student); verr != nil {
+			return verr
+		}
+		if err := checkUnique(tx, student, id); err != nil {
+			return err
+		}
+		if err := checkCapacity(tx, student, id); err != nil {
+			return err
+		}
+		student.UpdatedAt = timestamp()
+		tx.Put(student)
+		return nil
+	})
+
+	var perr *patchError
+	var verr *validationError
+	var full *classFullError
+	switch {
+	case errors.Is(err, errStudentNotFound):
+		writeError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	case errors.Is(err, errPatchTestFailed):
+		writeError(w, http.StatusConflict, codePatchTestFailed, "Patch test operation failed")
+		return
+	case errors.Is(err, errDuplicateStudent):
+		writeError(w, http.StatusConflict, codeDuplicateStudent, "Student already exists")
+		return
+	case errors.As(err, &full):
+		writeClassFull(w, full)
+		return
+	case errors.As(err, &perr):
+		writeError(w, http.StatusBadRequest, codeInvalidPatch, perr.Error())
+		return
+	case errors.As(err, &verr):
+		writeValidationError(w, r, verr)
+		return
+	case err != nil:
+		ErrorLogger.Printf("Failed to patch student %s: %v", id, err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Internal server error")
+		return
+	}
+
+	InfoLogger.Printf("Patched student: %v", student)
+	notifyWebhook(eventStudentUpdated, student)
+	writeJSON(w, http.StatusOK, student)
+}
+
+// DELETE /student/v1/students/{studentId} - Soft delete a student by ID
+//
+// The optional X-Actor header records who performed the deletion, and an
+// optional reason, from ?reason= or a {"reason": "..."} body, why. Both are
+// shown in the trash. Deleting an already deleted student keeps the original
+// details.
+//
+// With If-Match, the delete only happens while the student still has that
+// ETag, answering 412 otherwise, so a client can't remove a record someone
+// else just changed. REQUIRE_IF_MATCH makes the header mandatory (428).
+func deleteStudent(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["studentId"]
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" && cfg.RequireIfMatch {
+		writeError(w, http.StatusPreconditionRequired, codePreconditionRequired, "If-Match header required")
+		return
+	}
+
+	reason, err := singleValue(r.URL.Query(), "reason")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	if reason == "" {
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		// The body is optional, so only malformed JSON is an error
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			ErrorLogger.Printf("Failed to decode request body: %v", err)
+			writeError(w, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
+			return
+		}
+		reason = req.Reason
+	}
+	reason = strings.TrimSpace(reason)
+	if errs := checkLength(nil, "reason", reason, cfg.MaxDeleteReasonLength); len(errs) > 0 {
+		writeValidationError(w, r, &validationError{Fields: errs})
+		return
+	}
+
+	var student Student
+	err = store.Update(id, func(tx storeTx) error {
+		var exists bool
+		student, exists = tx.Get(id)
+		if !exists {
+			return errStudentNotFound
+		}
+		if ifMatch != "" {
+			if student.IsDeleted {
+				return errPreconditionFailed
+			}
+			etag, err := studentETag(student)
+			if err != nil {
+				return err
+			}
+			if !ifMatchSatisfied(ifMatch, etag) {
+				return errPreconditionFailed
+			}
+		}
+		if !student.IsDeleted {
+			deletedAt := timestamp()
+			student.IsDeleted = true
+			student.DeletedAt = &deletedAt
+			student.UpdatedAt = deletedAt
+			student.DeletedBy = r.Header.Get("X-Actor")
+			student.DeleteReason = reason
+			tx.Put(student)
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errStudentNotFound):
+		writeError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	case errors.Is(err, errPreconditionFailed):
+		writeError(w, http.StatusPreconditionFailed, codePreconditionFailed, "Student has changed since the given ETag")
+		return
+	case err != nil:
+		ErrorLogger.Printf("Failed to delete student %s: %v", id, err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Internal server error")
+		return
+	}
+
+	InfoLogger.Printf("Deleted student: %v", student)
+	notifyWebhook(eventStudentDeleted, student)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// API version reported by the service index
+const apiVersion = "v1"
+
+// GET / - Service index listing the registered endpoints
+func indexHandler(router *mux.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var endpoints []string
+		router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			path, err := route.GetPathTemplate()
+			if err != nil {
+				return nil
+			}
+			methods, err := route.GetMethods()
+			if err != nil {
+				return nil
+			}
+			for _, method := range methods {
+				endpoints = append(endpoints, method+" "+path)
+			}
+			return nil
+		})
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"service":   "student-api",
+			"version":   apiVersion,
+			"endpoints": endpoints,
+		})
+	}
+}
+
+// GET /health - Liveness probe. When ENROLLMENT_SEQUENCE_FILE is set it also
+// checks that file's directory still accepts writes, answering 503 degraded
+// if not, since a full disk would otherwise only show up in the error log.
+func healthCheck(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{"status": "ok"}
+	status := http.StatusOK
+	if cfg.EnrollmentSequenceFile != "" {
+		if err := probeWritable(filepath.Dir(cfg.EnrollmentSequenceFile)); err != nil {
+			ErrorLogger.Printf("Health check: sequence file directory not writable: %v", err)
+			response["status"] = "degraded"
+			response["checks"] = map[string]string{"sequence_file": err.Error()}
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	setRetryAfter(w, status)
+	writeJSON(w, status, response)
+}
+
+func main() {
+	mustLoadConfig()
+	mustOpenStore()
+	loadSequence()
+
+	r := newRouter()
+	handler := chain(middlewareStack()...)(r)
+	if cfg.StartupSelfCheck {
+		startupSelfCheck(r, handler)
+	}
+
+	srv := &http.Server{Addr: listenAddr, Handler: handler}
+
+	InfoLogger.Printf("Starting server on %s", listenAddr)
+	serveWithGracefulShutdown(srv)
+}
+
+// newRouter registers every route under the current configuration. The
+// global middleware from middlewareStack is left for the caller to wrap it in.
+func newRouter() *mux.Router {
+	r := mux.NewRouter()
+	r.NotFoundHandler = http.HandlerFunc(routeNotFound)
+	r.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowed)
+	if cfg.StrictBody {
+		r.Use(strictBodyMiddleware)
+	}
+	r.HandleFunc("/", indexHandler(r)).Methods("GET")
+	r.HandleFunc("/health", healthCheck).Methods("GET")
+	r.HandleFunc("/time", getTime).Methods("GET")
+	r.HandleFunc("/student/v1/schema", getSchema).Methods("GET")
+	r.HandleFunc("/student/v1/students", createStudent).Methods("POST")
+	r.HandleFunc("/student/v1/students", getAllStudents).Methods("GET")
+	r.HandleFunc("/student/v1/students", countStudents).Methods("HEAD")
+	r.HandleFunc("/student/v1/students.xlsx", exportStudentsXLSX).Methods("GET")
+	r.Handle("/student/v1/students", adminMiddleware(http.HandlerFunc(deleteAllStudents))).Methods("DELETE")
+	r.HandleFunc("/student/v1/students/sync", syncStudents).Methods("POST")
+	r.HandleFunc("/student/v1/students/bulk", bulkCreateStudents).Methods("POST")
+	r.HandleFunc("/student/v1/students/import", importStudents).Methods("POST")
+	r.HandleFunc("/student/v1/students/batch-delete", batchDeleteStudents).Methods("POST")
+	r.HandleFunc("/student/v1/students/batch-get", batchGetStudents).Methods("POST")
+	r.HandleFunc("/student/v1/students/assign-subject", assignSubject).Methods("POST")
+	r.HandleFunc("/student/v1/students/export", exportStudents).Methods("POST")
+	r.HandleFunc("/student/v1/students/assign-ids", assignEnrollmentNumbers).Methods("POST")
+	r.HandleFunc("/student/v1/students/validate", validateStudentPayload).Methods("POST")
+	r.HandleFunc("/student/v1/students/random", getRandomStudent).Methods("GET")
+	r.HandleFunc("/student/v1/students/by-class", getStudentsByClass).Methods("GET")
+	r.HandleFunc("/student/v1/students/ids", getStudentIDs).Methods("GET")
+	r.HandleFunc("/student/v1/students/lookup", lookupStudent).Methods("GET")
+	r.HandleFunc("/student/v1/students/{studentId}", getStudent).Methods("GET")
+	r.HandleFunc("/student/v1/students/{studentId}", updateStudent).Methods("PUT")
+	r.HandleFunc("/student/v1/students/{studentId}", patchStudent).Methods("PATCH")
+	r.HandleFunc("/student/v1/students/{studentId}", deleteStudent).Methods("DELETE")
+	r.HandleFunc("/student/v1/students/{studentId}/restore", restoreStudent).Methods("POST")
+	r.HandleFunc("/student/v1/students/{studentId}/subjects", getStudentSubjects).Methods("GET")
+	r.HandleFunc("/student/v1/students/{studentId}/subjects", addStudentSubject).Methods("POST")
+	r.HandleFunc("/student/v1/students/{studentId}/subjects", removeStudentSubject).Methods("DELETE")
+	r.HandleFunc("/student/v1/students/{studentId}/tags", getStudentTags).Methods("GET")
+	r.HandleFunc("/student/v1/students/{studentId}/tags/{key}", getStudentTag).Methods("GET")
+	r.HandleFunc("/student/v1/students/{studentId}/tags/{key}", setStudentTag).Methods("PUT")
+	r.HandleFunc("/student/v1/students/{studentId}/tags/{key}", deleteStudentTag).Methods("DELETE")
+	r.HandleFunc("/student/v1/students/{studentId}/history", getStudentHistory).Methods("GET")
+	r.HandleFunc("/student/v1/students/{studentId}/revert", revertStudent).Methods("POST")
+	r.HandleFunc("/student/v1/students/{studentId}/merge", mergeStudent).Methods("POST")
+	r.HandleFunc("/student/v1/trash", getTrash).Methods("GET")
+	r.HandleFunc("/student/v1/classes/{class}/promote", promoteClass).Methods("POST")
+	r.HandleFunc("/student/v1/stats/by-subject", getStatsBySubject).Methods("GET")
+	r.HandleFunc("/student/v1/stats/age-histogram", getAgeHistogram).Methods("GET")
+
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.Use(adminMiddleware)
+	admin.HandleFunc("/ui", adminUI).Methods("GET")
+	admin.HandleFunc("/backup", backupStore).Methods("GET")
+	admin.HandleFunc("/restore", restoreStore).Methods("POST")
+	admin.HandleFunc("/validate-data", validateData).Methods("POST")
+	admin.HandleFunc("/export-archive", exportArchive).Methods("GET")
+	admin.HandleFunc("/import-archive", importArchive).Methods("POST")
+	return r
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// newTestHandler returns the full handler, global middleware included, over
+// an empty memory store. configure, when non-nil, adjusts the default
+// configuration before the routes are built. The previous configuration and
+// store are put back when the test ends.
+func newTestHandler(t testing.TB, configure func(*Config)) http.Handler {
+	t.Helper()
+	savedCfg, savedStore := cfg, store
+	c := defaultConfig()
+	if configure != nil {
+		configure(&c)
+	}
+	cfg, store = c, newStudentStore()
+	resetState()
+	t.Cleanup(func() {
+		cfg, store = savedCfg, savedStore
+		resetState()
+	})
+	return chain(middlewareStack()...)(newRouter())
+}
+
+// newTestServer serves newTestHandler over HTTP
+func newTestServer(t testing.TB, configure func(*Config)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(newTestHandler(t, configure))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// seedStudents puts n active students straight into the store, numbered
+// E000000 onwards
+func seedStudents(n int) {
+	store.Exclusive(func(tx storeTx) error {
+		for i := 0; i < n; i++ {
+			tx.Put(Student{
+				EnrollmentNumber: fmt.Sprintf("E%06d", i),
+				Name:             fmt.Sprintf("Student %d", i),
+				Age:              8 + i%10,
+				Class:            fmt.Sprintf("%dA", 1+i%10),
+				Subjects:         []string{"Math"},
+				CreatedAt:        timestamp(),
+				UpdatedAt:        timestamp(),
+			})
+		}
+		return nil
+	})
+}
+
+// resetState forgets what earlier tests left behind outside the store
+func resetState() {
+	history.reset()
+	responseCache = &listCache{entries: make(map[string]cachedList)}
+	recentCreatesMu.Lock()
+	recentCreates = make(map[string]recentCreate)
+	recentCreatesMu.Unlock()
+}
+
+// doJSON sends body, encoded as JSON unless it is already a string, and
+// returns the response with its body read
+func doJSON(t testing.TB, srv *httptest.Server, method, path string, body interface{}, header ...string) (*http.Response, []byte) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		raw, ok := body.(string)
+		if !ok {
+			encoded, err := json.Marshal(body)
+			if err != nil {
+				t.Fatalf("encoding request body: %v", err)
+			}
+			raw = string(encoded)
+		}
+		reader = bytes.NewReader([]byte(raw))
+	}
+	req, err := http.NewRequest(method, srv.URL+path, reader)
+	if err != nil {
+		t.Fatalf("building %s %s: %v", method, path, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading %s %s: %v", method, path, err)
+	}
+	return resp, data
+}
+
+// mustCreate creates student through the API and returns its enrollment
+// number
+func mustCreate(t testing.TB, srv *httptest.Server, student interface{}) string {
+	t.Helper()
+	resp, body := doJSON(t, srv, http.MethodPost, "/student/v1/students", student)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("create: status %d: %s", resp.StatusCode, body)
+	}
+	var created struct {
+		EnrollmentNumber string `json:"enrollment_number"`
+	}
+	if err := json.Unmarshal(body, &created); err != nil {
+		t.Fatalf("create: decoding %s: %v", body, err)
+	}
+	return created.EnrollmentNumber
+}
+
+// TestConcurrentCreateGetDelete hammers the handlers from many goroutines at
+// once; run it with -race to check the store's locking invariants
+func TestConcurrentCreateGetDelete(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	const workers, perWorker = 16, 24
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				student := map[string]interface{}{"name": fmt.Sprintf("Student %d-%d", w, i), "age": 12, "class": "7B"}
+				encoded, _ := json.Marshal(student)
+				resp, err := srv.Client().Post(srv.URL+"/student/v1/students", "application/json", bytes.NewReader(encoded))
+				if err != nil {
+					errs <- err
+					return
+				}
+				var created struct {
+					EnrollmentNumber string `json:"enrollment_number"`
+				}
+				json.NewDecoder(resp.Body).Decode(&created)
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					errs <- fmt.Errorf("create: status %d", resp.StatusCode)
+					return
+				}
+
+				for _, path := range []string{"/student/v1/students/" + created.EnrollmentNumber, "/student/v1/students?limit=5"} {
+					resp, err := srv.Client().Get(srv.URL + path)
+					if err != nil {
+						errs <- err
+						return
+					}
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+					if resp.StatusCode != http.StatusOK {
+						errs <- fmt.Errorf("GET %s: status %d", path, resp.StatusCode)
+						return
+					}
+				}
+
+				if i%2 == 0 {
+					req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/student/v1/students/"+created.EnrollmentNumber, nil)
+					resp, err := srv.Client().Do(req)
+					if err != nil {
+						errs <- err
+						return
+					}
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+					if resp.StatusCode >= 300 {
+						errs <- fmt.Errorf("delete: status %d", resp.StatusCode)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// Every even-numbered create was deleted again
+	if got, want := len(store.Active()), workers*perWorker/2; got != want {
+		t.Errorf("active students = %d, want %d", got, want)
+	}
+}
+
+// BenchmarkListSnapshot lists the first page of 10k students with the
+// cached sorted snapshot and with the full scan it replaces
+func BenchmarkListSnapshot(b *testing.B) {
+	for _, snapshot := range []bool{true, false} {
+		b.Run(fmt.Sprintf("snapshot=%t", snapshot), func(b *testing.B) {
+			handler := newTestHandler(b, func(c *Config) {
+				c.ListSnapshot = snapshot
+				c.HistoryLimit = 0
+			})
+			seedStudents(10000)
+			req := httptest.NewRequest(http.MethodGet, "/student/v1/students?limit=20", nil)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK {
+					b.Fatalf("status %d: %s", rec.Code, rec.Body)
+				}
+			}
+		})
+	}
+}
+
+// Store sizes the handler benchmarks run against
+var benchmarkSizes = []int{100, 10000, 100000}
+
+func BenchmarkCreateStudent(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("students=%d", size), func(b *testing.B) {
+			handler := newTestHandler(b, nil)
+			seedStudents(size)
+			body := []byte(`{"name":"Ann","age":10,"class":"5A","subjects":["Math"]}`)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				req := httptest.NewRequest(http.MethodPost, "/student/v1/students", bytes.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK {
+					b.Fatalf("status %d: %s", rec.Code, rec.Body)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetStudent(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("students=%d", size), func(b *testing.B) {
+			handler := newTestHandler(b, nil)
+			seedStudents(size)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/student/v1/students/E%06d", i%size), nil)
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK {
+					b.Fatalf("status %d: %s", rec.Code, rec.Body)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkGetAllStudents lists with the default page size, so larger stores
+// cost more to keep sorted but return at most MAX_LIST_RESULTS students
+func BenchmarkGetAllStudents(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("students=%d", size), func(b *testing.B) {
+			handler := newTestHandler(b, nil)
+			seedStudents(size)
+			req := httptest.NewRequest(http.MethodGet, "/student/v1/students", nil)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK {
+					b.Fatalf("status %d: %s", rec.Code, rec.Body)
+				}
+			}
+		})
+	}
+}
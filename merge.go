@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gorilla/mux"
+)
+
+var errSameStudent = errors.New("cannot merge a student into itself")
+
+// mergeStudents folds from into keep: keep's identity and non-empty fields
+// win, from fills in the rest, and the subjects are the union of both
+func mergeStudents(keep, from Student) Student {
+	if keep.Name == "" {
+		keep.Name = from.Name
+	}
+	if keep.Age == 0 {
+		keep.Age = from.Age
+	}
+	if keep.DateOfBirth == "" {
+		keep.DateOfBirth = from.DateOfBirth
+	}
+	if keep.Class == "" {
+		keep.Class = from.Class
+	}
+
+	subjects := slices.Clone(keep.Subjects)
+	for _, subject := range from.Subjects {
+		if !hasSubject(subjects, subject) {
+			subjects = append(subjects, subject)
+		}
+	}
+	keep.Subjects = subjects
+	return keep
+}
+
+// POST /student/v1/students/{studentId}/merge - Merge the duplicate named by
+// {"merge_id": "..."} into this student and soft-delete the duplicate, in one
+// write. Answers with the merged record.
+func mergeStudent(w http.ResponseWriter, r *http.Request) {
+	keepID := mux.Vars(r)["studentId"]
+
+	var req struct {
+		MergeID string `json:"merge_id"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ErrorLogger.Printf("Failed to decode request body: %v", err)
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
+		return
+	}
+	if req.MergeID == "" {
+		writeValidationError(w, r, &validationError{Fields: []fieldError{newFieldError("merge_id", fieldRequired)}})
+		return
+	}
+
+	var merged, removed Student
+	var verr *validationError
+	err = store.Exclusive(func(tx storeTx) error {
+		if storeKey(keepID) == storeKey(req.MergeID) {
+			return errSameStudent
+		}
+		keep, exists := tx.Get(keepID)
+		if !exists || keep.IsDeleted {
+			return errStudentNotFound
+		}
+		from, exists := tx.Get(req.MergeID)
+		if !exists || from.IsDeleted {
+			return errStudentNotFound
+		}
+
+		merged = mergeStudents(keep, from)
+		if verr = validateStudent(merged); verr != nil {
+			return verr
+		}
+
+		// Retire the duplicate first, so it doesn't conflict with the record
+		// it is merged into
+		deletedAt := timestamp()
+		removed = from
+		removed.IsDeleted = true
+		removed.DeletedAt = &deletedAt
+		removed.UpdatedAt = deletedAt
+		removed.DeletedBy = r.Header.Get("X-Actor")
+		tx.Put(removed)
+
+		merged.UpdatedAt = deletedAt
+		if err := checkUnique(tx, merged, keepID); err != nil {
+			return err
+		}
+		if err := checkCapacity(tx, merged, keepID); err != nil {
+			return err
+		}
+		tx.Put(merged)
+		return nil
+	})
+	var full *classFullError
+	switch {
+	case errors.Is(err, errSameStudent):
+		writeError(w, http.StatusConflict, codeSameStudent, "Cannot merge a student into itself")
+		return
+	case errors.Is(err, errStudentNotFound):
+		writeError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	case verr != nil:
+		writeValidationError(w, r, verr)
+		return
+	case errors.As(err, &full):
+		writeClassFull(w, full)
+		return
+	case err != nil:
+		writeError(w, http.StatusConflict, codeDuplicateStudent, "Student already exists")
+		return
+	}
+
+	InfoLogger.Printf("Merged student %s into %s", removed.EnrollmentNumber, merged.EnrollmentNumber)
+	notifyWebhook(eventStudentDeleted, removed)
+	notifyWebhook(eventStudentUpdated, merged)
+	writeJSON(w, http.StatusOK, merged)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Machine-readable reasons a field fails validation, returned in each field
+// error's "code". They stay the same whatever language the message is in.
+const (
+	fieldRequired         = "required"
+	fieldOutOfRange       = "out_of_range"
+	fieldDuplicateSubject = "duplicate_subject"
+	fieldPatternMismatch  = "pattern_mismatch"
+	fieldInvalidDate      = "invalid_date"
+	fieldNotInPast        = "not_in_past"
+	fieldAgeOutOfRange    = "age_out_of_range"
+	fieldNotAllowed       = "not_allowed"
+	fieldMustBeEmpty      = "must_be_empty"
+	fieldNotInferable     = "not_inferable"
+	fieldTooLong          = "too_long"
+	fieldAlreadyExists    = "already_exists"
+	fieldClassFull        = "class_full"
+	fieldTooMany          = "too_many"
+	fieldBelowMinimum     = "below_minimum"
+	fieldAboveMaximum     = "above_maximum"
+	fieldNotInteger       = "not_integer"
+)
+
+// Language validation messages fall back to when Accept-Language names none
+// of the supported ones
+const defaultLanguage = "en"
+
+// validationMessages holds the message template for each field code, by
+// language. Templates take the arguments the fieldError was created with.
+var validationMessages = map[string]map[string]string{
+	"en": {
+		codeValidationFailed:  "validation failed",
+		fieldRequired:         "must not be empty",
+		fieldOutOfRange:       "must be between %d and %d",
+		fieldDuplicateSubject: "duplicate subject",
+		fieldPatternMismatch:  "must match %s",
+		fieldInvalidDate:      "must be a date formatted as YYYY-MM-DD",
+		fieldNotInPast:        "must be in the past",
+		fieldAgeOutOfRange:    "must give an age between %d and %d",
+		fieldNotAllowed:       "must be one of %s",
+		fieldMustBeEmpty:      "must be empty",
+		fieldNotInferable:     "must be given, or inferable from a subject",
+		fieldTooLong:          "must be at most %d characters, got %d",
+		fieldAlreadyExists:    "already exists",
+		fieldClassFull:        "class %s is full (capacity %d)",
+		fieldTooMany:          "must have at most %d entries, got %d",
+		fieldBelowMinimum:     "must be at least %d",
+		fieldAboveMaximum:     "must be at most %d",
+		fieldNotInteger:       "must be a whole number",
+	},
+	"es": {
+		codeValidationFailed:  "la validación falló",
+		fieldRequired:         "no debe estar vacío",
+		fieldOutOfRange:       "debe estar entre %d y %d",
+		fieldDuplicateSubject: "asignatura duplicada",
+		fieldPatternMismatch:  "debe coincidir con %s",
+		fieldInvalidDate:      "debe ser una fecha con formato AAAA-MM-DD",
+		fieldNotInPast:        "debe estar en el pasado",
+		fieldAgeOutOfRange:    "debe corresponder a una edad entre %d y %d",
+		fieldNotAllowed:       "debe ser uno de %s",
+		fieldMustBeEmpty:      "debe estar vacío",
+		fieldNotInferable:     "debe indicarse o poder deducirse de una asignatura",
+		fieldTooLong:          "debe tener como máximo %d caracteres, tiene %d",
+		fieldAlreadyExists:    "ya existe",
+		fieldClassFull:        "la clase %s está llena (capacidad %d)",
+		fieldTooMany:          "debe tener como máximo %d entradas, tiene %d",
+		fieldBelowMinimum:     "debe ser como mínimo %d",
+		fieldAboveMaximum:     "debe ser como máximo %d",
+		fieldNotInteger:       "debe ser un número entero",
+	},
+}
+
+// newFieldError builds a field error with its message in the default
+// language; localize rewrites it for the client's
+func newFieldError(field, code string, args ...interface{}) fieldError {
+	return fieldError{Field: field, Code: code, Message: message(defaultLanguage, code, args...), args: args}
+}
+
+// message renders the template for code in lang
+func message(lang, code string, args ...interface{}) string {
+	template, ok := validationMessages[lang][code]
+	if !ok {
+		template = validationMessages[defaultLanguage][code]
+	}
+	if len(args) == 0 {
+		return template
+	}
+	return fmt.Sprintf(template, args...)
+}
+
+// localize returns fields with their messages rendered in lang
+func localize(fields []fieldError, lang string) []fieldError {
+	localized := make([]fieldError, len(fields))
+	for i, f := range fields {
+		localized[i] = f
+		if f.Code != "" {
+			localized[i].Message = message(lang, f.Code, f.args...)
+		}
+	}
+	return localized
+}
+
+// requestLanguage picks the supported language the client prefers most from
+// Accept-Language, matching on the primary subtag so "es-MX" gets Spanish
+func requestLanguage(r *http.Request) string {
+	type preference struct {
+		lang string
+		q    float64
+	}
+	var prefs []preference
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if _, supported := validationMessages[primary]; supported && q > 0 {
+			prefs = append(prefs, preference{lang: primary, q: q})
+		}
+	}
+	if len(prefs) == 0 {
+		return defaultLanguage
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+	return prefs[0].lang
+}
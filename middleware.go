@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// chain composes middleware so the first argument is the outermost layer,
+// i.e. chain(a, b)(h) serves requests as a(b(h))
+func chain(middlewares ...func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(final http.Handler) http.Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			final = middlewares[i](final)
+		}
+		return final
+	}
+}
+
+// middlewareStack returns the global middleware in the order requests pass
+// through it. Everything here wraps the router, so it also covers requests
+// that match no route.
+//  1. inFlightMiddleware: first, so shutdown sees every request being served
+//  2. requestLogMiddleware: times everything below it, including rejections
+//  3. bodyLogMiddleware (LOG_BODIES only): beside the access log, so it sees
+//     the bodies exactly as the client sent and received them
+//  4. responseHeadersMiddleware: before anything that can answer on its own,
+//     so rejections carry the headers too
+//  5. problemMiddleware (ERROR_FORMAT=problem only): above every layer that
+//     can reject a request, so all of their errors are rewritten
+//  6. forceHTTPSMiddleware (FORCE_HTTPS only): redirects before a plain-HTTP
+//     request gets any further
+//  7. concurrencyLimitMiddleware (MAX_CONCURRENT only): sheds load before any
+//     work is done for the request
+//  8. timeoutMiddleware: sets the deadline and answers 504 in its own name
+//  9. trailingSlashMiddleware (STRICT_SLASH only): rewrites the path before
+//     anything inspects it
+//  10. queryLimitMiddleware: rejects abusive queries before handlers parse them
+//  11. contentTypeMiddleware (STRICT_CONTENT_TYPE only): rejects non-JSON
+//     bodies before handlers decode them
+//  12. camelCaseMiddleware (JSON_NAMING=camel only): innermost, so it rewrites
+//     exactly what handlers produced
+//
+// Per-route guards such as adminMiddleware are applied on subrouters instead,
+// and strictBodyMiddleware (STRICT_BODY only) on the router itself, since it
+// needs the matched route.
+func middlewareStack() []func(http.Handler) http.Handler {
+	stack := []func(http.Handler) http.Handler{inFlightMiddleware, requestLogMiddleware}
+	if cfg.LogBodies {
+		stack = append(stack, bodyLogMiddleware)
+	}
+	stack = append(stack, responseHeadersMiddleware)
+	if cfg.ErrorFormat == errorFormatProblem {
+		stack = append(stack, problemMiddleware)
+	}
+	if cfg.ForceHTTPS {
+		stack = append(stack, forceHTTPSMiddleware)
+	}
+	if cfg.MaxConcurrent > 0 {
+		stack = append(stack, concurrencyLimitMiddleware(cfg.MaxConcurrent))
+	}
+	stack = append(stack, timeoutMiddleware)
+	if cfg.StrictSlash {
+		stack = append(stack, trailingSlashMiddleware)
+	}
+	stack = append(stack, queryLimitMiddleware)
+	if cfg.StrictContentType {
+		stack = append(stack, contentTypeMiddleware)
+	}
+	if cfg.JSONNaming == namingCamel {
+		stack = append(stack, camelCaseMiddleware)
+	}
+	return stack
+}
+
+// responseHeadersMiddleware adds the RESPONSE_HEADERS to every response. They
+// are set before the handler runs, so anything a handler sets itself wins.
+func responseHeadersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, value := range cfg.ResponseHeaders {
+			w.Header().Set(name, value)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forceHTTPSMiddleware answers requests a TLS-terminating proxy received over
+// plain HTTP, as told by X-Forwarded-Proto, with a 308 to the same URL over
+// HTTPS. 308 keeps the method and body, unlike 301. Requests without the
+// header didn't come through the proxy and pass, so the redirected request
+// can never be redirected again.
+func forceHTTPSMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A chain of proxies lists protocols in order; the first is the client's
+		proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+		if exemptPaths[r.URL.Path] || !strings.EqualFold(strings.TrimSpace(proto), "http") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		http.Redirect(w, r, "https://"+r.Host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// Internal endpoints that bypass request hardening checks
+var exemptPaths = map[string]bool{
+	"/health": true,
+}
+
+// queryLimitMiddleware rejects requests whose query string is too long or
+// carries too many parameters, before any handler parses it
+func queryLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exemptPaths[r.URL.Path] || r.URL.RawQuery == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		raw := r.URL.RawQuery
+		if len(raw) > cfg.MaxQueryLength {
+			ErrorLogger.Printf("Rejected request with query string of %d bytes: %s", len(raw), r.URL.Path)
+			writeError(w, http.StatusBadRequest, codeQueryTooLarge, "Query string too long")
+			return
+		}
+
+		// Count separators rather than parsing, so oversized inputs stay cheap
+		if params := strings.Count(raw, "&") + strings.Count(raw, ";") + 1; params > cfg.MaxQueryParams {
+			ErrorLogger.Printf("Rejected request with %d query parameters: %s", params, r.URL.Path)
+			writeError(w, http.StatusBadRequest, codeQueryTooLarge, "Too many query parameters")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isJSONMediaType reports whether a Content-Type header names JSON, either
+// application/json or a +json type, whatever parameters such as charset it
+// carries. Malformed parameters don't matter; the media type itself must parse.
+func isJSONMediaType(header string) bool {
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil && !errors.Is(err, mime.ErrInvalidMediaParameter) {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// Endpoints that take CSV uploads besides JSON
+var csvUploadPaths = map[string]bool{
+	"/student/v1/students/import": true,
+}
+
+// contentTypeMiddleware answers 415 for POST and PUT requests whose body is
+// not declared as JSON, or as CSV on csvUploadPaths. PATCH negotiates its own
+// patch formats.
+func contentTypeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType := r.Header.Get("Content-Type")
+		if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == csvContentType && csvUploadPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if (r.Method == http.MethodPost || r.Method == http.MethodPut) && r.ContentLength != 0 &&
+			!isJSONMediaType(contentType) {
+			writeError(w, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "Request body must be application/json")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Routes whose DELETE reads an optional body, exempt from
+// strictBodyMiddleware, by path template
+var deleteBodyRoutes = map[string]bool{
+	"/student/v1/students/{studentId}": true, // {"reason": ...}, see deleteStudent
+}
+
+// strictBodyMiddleware answers 400 for GET and DELETE requests that carry a
+// body, which would otherwise be ignored without a word, unless the route is
+// in deleteBodyRoutes. A body is any declared Content-Length above zero, or
+// a chunked one of unknown length. It runs on the router, after matching, so
+// it can tell routes apart by template.
+func strictBodyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.Method == http.MethodGet || r.Method == http.MethodDelete) && r.ContentLength != 0 {
+			template, _ := mux.CurrentRoute(r).GetPathTemplate()
+			if r.Method == http.MethodGet || !deleteBodyRoutes[template] {
+				writeError(w, http.StatusBadRequest, codeUnexpectedBody, r.Method+" requests must not have a body")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// concurrencyLimitMiddleware serves at most limit requests at once and turns
+// the rest away with 503 immediately rather than queueing them
+func concurrencyLimitMiddleware(limit int) func(http.Handler) http.Handler {
+	slots := make(chan struct{}, limit)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exemptPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+				next.ServeHTTP(w, r)
+			default:
+				ErrorLogger.Printf("Rejected %s %s: %d requests already in flight", r.Method, r.URL.Path, limit)
+				writeError(w, http.StatusServiceUnavailable, codeServerBusy, "Server is busy, retry later")
+			}
+		})
+	}
+}
+
+// trailingSlashMiddleware strips a trailing slash so "/students/" and
+// "/students" reach the same handler. It rewrites the path in place instead
+// of redirecting like mux's StrictSlash, because a 301 makes many clients
+// retry POST/PUT/DELETE as GET. It must wrap the router, since mux matches
+// routes before its own middleware runs.
+func trailingSlashMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.URL.Path) > 1 && strings.HasSuffix(r.URL.Path, "/") {
+			r.URL.Path = strings.TrimRight(r.URL.Path, "/")
+			if r.URL.Path == "" {
+				r.URL.Path = "/"
+			}
+			r.URL.RawPath = ""
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConcurrencyLimitSheds(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	handler := concurrencyLimitMiddleware(1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/student/v1/students", nil))
+	}()
+	<-entered
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/student/v1/students", nil))
+	close(release)
+	<-done
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("second request: status %d, want 503", rec.Code)
+	}
+	if got, want := rec.Header().Get("Retry-After"), defaultRetryAfter[http.StatusServiceUnavailable]; got != want {
+		t.Errorf("Retry-After = %q, want %q from setRetryAfter", got, want)
+	}
+}
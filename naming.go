@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// JSON field naming styles selectable via JSON_NAMING
+const (
+	namingSnake = "snake"
+	namingCamel = "camel"
+)
+
+// Keys that look like snake_case identifiers get converted; anything else
+// (class names like "10A", histogram buckets) passes through untouched
+var snakeKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)+$`)
+
+// camelCaseMiddleware rewrites the keys of JSON responses from snake_case to
+// camelCase (enrollment_number -> enrollmentNumber). It only changes the wire
+// format of responses: storage, request bodies and query parameters keep
+// their snake_case names. NDJSON streams such as the import's events pass
+// through as written, so each line reaches the client when it is flushed;
+// their keys are single words that camelCase leaves alone anyway.
+func camelCaseMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &bufferedResponse{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		// A handler that wrote nothing, such as one that gave up at its
+		// deadline, leaves the reply to the layers above
+		if rec.streaming || !rec.wrote {
+			return
+		}
+
+		body := rec.body.Bytes()
+		if isJSONMediaType(w.Header().Get("Content-Type")) {
+			if converted, err := camelCaseJSON(body, rec.dataKeyed); err == nil {
+				body = converted
+			} else {
+				ErrorLogger.Printf("Failed to convert response to camelCase: %v", err)
+			}
+		}
+
+		if w.Header().Get("Content-Length") != "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		}
+		w.WriteHeader(rec.status)
+		w.Write(body)
+	})
+}
+
+// bufferedResponse captures a handler's response so it can be rewritten,
+// unless it turns out to be an NDJSON stream
+type bufferedResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+	// dataKeyed marks responses whose top-level keys are data, not field names
+	dataKeyed bool
+	// streaming is set once the handler starts an NDJSON response, which is
+	// written straight to the client instead of buffered
+	streaming bool
+	// wrote is set once the handler starts its response at all
+	wrote bool
+}
+
+// markDataKeyed tells camelCaseMiddleware that the response's top-level
+// object is keyed by data (e.g. class names) which must not be renamed
+func markDataKeyed(w http.ResponseWriter) {
+	if rec, ok := w.(*bufferedResponse); ok {
+		rec.dataKeyed = true
+	}
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.wrote = true
+	b.status = status
+	if mediaType, _, _ := mime.ParseMediaType(b.Header().Get("Content-Type")); mediaType == ndjsonContentType {
+		b.streaming = true
+		b.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.wrote = true
+	if b.streaming {
+		return b.ResponseWriter.Write(p)
+	}
+	return b.body.Write(p)
+}
+
+// Flush sends a stream's lines so far; buffered responses only go out once
+// the handler returns
+func (b *bufferedResponse) Flush() {
+	if b.streaming {
+		http.NewResponseController(b.ResponseWriter).Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (b *bufferedResponse) Unwrap() http.ResponseWriter {
+	return b.ResponseWriter
+}
+
+// camelCaseJSON re-encodes a JSON document with camelCase object keys,
+// leaving the top-level keys alone when dataKeyed is set
+func camelCaseJSON(body []byte, dataKeyed bool) ([]byte, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return body, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	if _, ok := doc.(map[string]interface{}); ok && dataKeyed {
+		return convertValues(body)
+	}
+	doc = convertKeys(doc)
+
+	var out bytes.Buffer
+	if err := json.NewEncoder(&out).Encode(doc); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// convertValues converts the values of a top-level object but keeps its keys,
+// and their order, as the handler wrote them
+func convertValues(body []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		var child interface{}
+		if err := decoder.Decode(&child); err != nil {
+			return nil, err
+		}
+		encodedKey, _ := json.Marshal(key)
+		encodedChild, err := json.Marshal(convertKeys(child))
+		if err != nil {
+			return nil, err
+		}
+		if out.Len() > 1 {
+			out.WriteByte(',')
+		}
+		out.Write(encodedKey)
+		out.WriteByte(':')
+		out.Write(encodedChild)
+	}
+	out.WriteString("}\n")
+	return out.Bytes(), nil
+}
+
+// Fields whose object values are keyed by client data, like a student's
+// tags, and so keep their keys as given
+var dataKeyedFields = map[string]bool{"tags": true}
+
+func convertKeys(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for key, child := range value {
+			if dataKeyedFields[key] {
+				converted[key] = child
+				continue
+			}
+			converted[snakeToCamel(key)] = convertKeys(child)
+		}
+		return converted
+	case []interface{}:
+		for i, child := range value {
+			value[i] = convertKeys(child)
+		}
+		return value
+	default:
+		return v
+	}
+}
+
+func snakeToCamel(key string) string {
+	if !snakeKeyPattern.MatchString(key) {
+		return key
+	}
+	parts := strings.Split(key, "_")
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Fields clients may never change through PATCH
+var readOnlyFields = map[string]bool{
+	"enrollment_number": true,
+	"created_at":        true,
+	"updated_at":        true,
+	"deleted_at":        true,
+	"deleted_by":        true,
+	"delete_reason":     true,
+}
+
+var errPatchTestFailed = errors.New("patch test operation failed")
+
+// patchError is a patch document that is malformed or cannot be applied
+type patchError struct {
+	msg string
+}
+
+func (e *patchError) Error() string {
+	return e.msg
+}
+
+func newPatchError(format string, args ...interface{}) error {
+	return &patchError{msg: fmt.Sprintf(format, args...)}
+}
+
+// patchOp is a single RFC 6902 operation
+type patchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// parseJSONPatch decodes and validates an RFC 6902 document. Only replace,
+// remove and test are supported.
+func parseJSONPatch(body []byte) ([]patchOp, error) {
+	var ops []patchOp
+	if err := json.Unmarshal(body, &ops); err != nil {
+		return nil, newPatchError("%s", decodeErrorMessage(err))
+	}
+
+	for i, op := range ops {
+		switch op.Op {
+		case "replace", "remove", "test":
+		default:
+			return nil, newPatchError("operation %d: unsupported op %q", i, op.Op)
+		}
+		tokens, err := parsePointer(op.Path)
+		if err != nil {
+			return nil, newPatchError("operation %d: %v", i, err)
+		}
+		if len(tokens) == 0 {
+			return nil, newPatchError("operation %d: path must not target the whole document", i)
+		}
+		if readOnlyFields[tokens[0]] {
+			return nil, newPatchError("operation %d: %s is read-only", i, tokens[0])
+		}
+	}
+	return ops, nil
+}
+
+// parseMergePatch decodes an RFC 7396 merge patch object
+func parseMergePatch(body []byte) (map[string]interface{}, error) {
+	var patch map[string]interface{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return nil, newPatchError("%s", decodeErrorMessage(err))
+	}
+	for field := range patch {
+		if readOnlyFields[field] {
+			return nil, newPatchError("%s is read-only", field)
+		}
+	}
+
+	// subject is the old single-subject field, see Student.UnmarshalJSON.
+	// Left in place it would sit beside the stored subjects after the merge.
+	if value, ok := patch["subject"]; ok {
+		if _, both := patch["subjects"]; both {
+			return nil, newPatchError("%v", errSubjectAndSubjects)
+		}
+		delete(patch, "subject")
+		switch subject := value.(type) {
+		case nil:
+			patch["subjects"] = nil
+		case string:
+			patch["subjects"] = []interface{}{}
+			if subject != "" {
+				patch["subjects"] = []interface{}{subject}
+			}
+		default:
+			return nil, newPatchError("Invalid request payload: subject expected string")
+		}
+	}
+	return patch, nil
+}
+
+// Fields PATCH accepts as query parameters, e.g. ?class=11A&subjects=Physics.
+// subjects takes a comma-separated list; subject is accepted as an alias.
+var queryPatchFields = map[string]string{
+	"name":          "name",
+	"age":           "age",
+	"date_of_birth": "date_of_birth",
+	"class":         "class",
+	"subjects":      "subjects",
+	"subject":       "subjects",
+}
+
+// parseQueryPatch turns query parameters into the equivalent merge patch, so
+// they go through the same validation as a JSON body
+func parseQueryPatch(values url.Values) (map[string]interface{}, error) {
+	patch := make(map[string]interface{})
+	for key := range values {
+		field, ok := queryPatchFields[key]
+		if !ok {
+			return nil, newPatchError("unknown parameter %q", key)
+		}
+		if _, dup := patch[field]; dup {
+			return nil, newPatchError("%s given more than once", field)
+		}
+		value, err := singleValue(values, key)
+		if err != nil {
+			return nil, err
+		}
+
+		switch field {
+		case "age":
+			age, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, newPatchError("invalid age: must be an integer")
+			}
+			patch[field] = age
+		case "subjects":
+			subjects := []interface{}{}
+			for _, subject := range strings.Split(value, ",") {
+				if subject = strings.TrimSpace(subject); subject != "" {
+					subjects = append(subjects, subject)
+				}
+			}
+			patch[field] = subjects
+		default:
+			patch[field] = value
+		}
+	}
+	return patch, nil
+}
+
+// applyPatch runs apply against the JSON form of student and decodes the
+// result back, keeping the fields PATCH may not touch
+func applyPatch(student Student, apply func(doc map[string]interface{}) error) (Student, error) {
+	raw, err := json.Marshal(student)
+	if err != nil {
+		return student, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return student, err
+	}
+
+	if err := apply(doc); err != nil {
+		return student, err
+	}
+
+	raw, err = json.Marshal(doc)
+	if err != nil {
+		return student, err
+	}
+	var patched Student
+	if err := json.Unmarshal(raw, &patched); err != nil {
+		return student, newPatchError("%s", decodeErrorMessage(err))
+	}
+
+	patched.EnrollmentNumber = student.EnrollmentNumber
+	patched.CreatedAt = student.CreatedAt
+	patched.UpdatedAt = student.UpdatedAt
+	patched.IsDeleted = student.IsDeleted
+	patched.DeletedAt = student.DeletedAt
+	patched.DeletedBy = student.DeletedBy
+	patched.DeleteReason = student.DeleteReason
+	return patched, nil
+}
+
+// applyMergePatch merges patch into doc per RFC 7396: null removes a member,
+// objects merge recursively and anything else replaces
+func applyMergePatch(doc, patch map[string]interface{}) {
+	for key, value := range patch {
+		if value == nil {
+			delete(doc, key)
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			target, ok := doc[key].(map[string]interface{})
+			if !ok {
+				target = make(map[string]interface{})
+			}
+			applyMergePatch(target, nested)
+			doc[key] = target
+			continue
+		}
+		doc[key] = value
+	}
+}
+
+// applyJSONPatch applies validated RFC 6902 operations to doc in order
+func applyJSONPatch(doc map[string]interface{}, ops []patchOp) error {
+	for i, op := range ops {
+		tokens, _ := parsePointer(op.Path)
+		parent, err := resolveParent(doc, tokens)
+		if err != nil {
+			return newPatchError("operation %d: %v", i, err)
+		}
+		last := tokens[len(tokens)-1]
+
+		switch op.Op {
+		case "test":
+			current, err := getChild(parent, last)
+			if err != nil {
+				return newPatchError("operation %d: %v", i, err)
+			}
+			if !jsonEqual(current, op.Value) {
+				return errPatchTestFailed
+			}
+		case "replace":
+			if _, err := getChild(parent, last); err != nil {
+				return newPatchError("operation %d: %v", i, err)
+			}
+			if err := setChild(parent, last, op.Value); err != nil {
+				return newPatchError("operation %d: %v", i, err)
+			}
+		case "remove":
+			if err := removeChild(doc, tokens); err != nil {
+				return newPatchError("operation %d: %v", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into unescaped tokens
+func parsePointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid path %q: must start with /", path)
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// resolveParent walks every token but the last, returning the container the
+// final token addresses
+func resolveParent(doc map[string]interface{}, tokens []string) (interface{}, error) {
+	var node interface{} = doc
+	for _, token := range tokens[:len(tokens)-1] {
+		child, err := getChild(node, token)
+		if err != nil {
+			return nil, err
+		}
+		node = child
+	}
+	return node, nil
+}
+
+func getChild(node interface{}, token string) (interface{}, error) {
+	switch container := node.(type) {
+	case map[string]interface{}:
+		value, ok := container[token]
+		if !ok {
+			return nil, fmt.Errorf("path member %q does not exist", token)
+		}
+		return value, nil
+	case []interface{}:
+		index, err := arrayIndex(container, token)
+		if err != nil {
+			return nil, err
+		}
+		return container[index], nil
+	default:
+		return nil, fmt.Errorf("path member %q is not inside an object or array", token)
+	}
+}
+
+func setChild(node interface{}, token string, value interface{}) error {
+	switch container := node.(type) {
+	case map[string]interface{}:
+		container[token] = value
+		return nil
+	case []interface{}:
+		index, err := arrayIndex(container, token)
+		if err != nil {
+			return err
+		}
+		container[index] = value
+		return nil
+	default:
+		return fmt.Errorf("path member %q is not inside an object or array", token)
+	}
+}
+
+// removeChild deletes the member tokens address. Removing from an array
+// shrinks it, so the new slice is written back into its own parent.
+func removeChild(doc map[string]interface{}, tokens []string) error {
+	parent, err := resolveParent(doc, tokens)
+	if err != nil {
+		return err
+	}
+	last := tokens[len(tokens)-1]
+
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := container[last]; !ok {
+			return fmt.Errorf("path member %q does not exist", last)
+		}
+		delete(container, last)
+		return nil
+	case []interface{}:
+		index, err := arrayIndex(container, last)
+		if err != nil {
+			return err
+		}
+		shrunk := append(container[:index:index], container[index+1:]...)
+		grandparent, err := resolveParent(doc, tokens[:len(tokens)-1])
+		if err != nil {
+			return err
+		}
+		return setChild(grandparent, tokens[len(tokens)-2], shrunk)
+	default:
+		return fmt.Errorf("path member %q is not inside an object or array", last)
+	}
+}
+
+func arrayIndex(array []interface{}, token string) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index >= len(array) {
+		return 0, fmt.Errorf("array index %q out of range", token)
+	}
+	return index, nil
+}
+
+// jsonEqual compares two decoded JSON values structurally
+func jsonEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Error body formats selectable via ERROR_FORMAT
+const (
+	errorFormatSimple  = "simple"
+	errorFormatProblem = "problem"
+)
+
+const problemContentType = "application/problem+json"
+
+// problemMiddleware rewrites error replies, whatever layer below wrote them,
+// into RFC 7807 problem details: the message becomes detail, the code stays
+// as an extension member alongside a type URI derived from it, and members
+// such as a validation error's fields are kept as extensions too. Only JSON
+// replies with a 4xx or 5xx status are held back; everything else streams
+// through untouched.
+func problemMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &problemResponse{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.held == nil {
+			return
+		}
+
+		body := rec.held.Bytes()
+		if converted, ok := problemDetails(body, rec.status, r.URL.RequestURI()); ok {
+			body = converted
+			w.Header().Set("Content-Type", problemContentType)
+		}
+		if w.Header().Get("Content-Length") != "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		}
+		w.WriteHeader(rec.status)
+		w.Write(body)
+	})
+}
+
+// problemResponse holds back the body of a JSON error reply so
+// problemMiddleware can rewrite it, and passes anything else straight on
+type problemResponse struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	held        *bytes.Buffer
+}
+
+func (p *problemResponse) WriteHeader(status int) {
+	if p.wroteHeader {
+		return
+	}
+	p.wroteHeader = true
+	if status >= 400 && isJSONMediaType(p.Header().Get("Content-Type")) {
+		p.status, p.held = status, &bytes.Buffer{}
+		return
+	}
+	p.ResponseWriter.WriteHeader(status)
+}
+
+func (p *problemResponse) Write(b []byte) (int, error) {
+	if !p.wroteHeader {
+		p.WriteHeader(http.StatusOK)
+	}
+	if p.held != nil {
+		return p.held.Write(b)
+	}
+	return p.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (p *problemResponse) Unwrap() http.ResponseWriter {
+	return p.ResponseWriter
+}
+
+// problemDetails converts an error body carrying a code into a problem
+// details document, reporting false for bodies that carry none
+func problemDetails(body []byte, status int, instance string) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var members map[string]interface{}
+	if decoder.Decode(&members) != nil {
+		return nil, false
+	}
+	code, _ := members["code"].(string)
+	if code == "" {
+		return nil, false
+	}
+
+	members["type"] = problemType(code)
+	members["title"] = problemTitle(code)
+	members["status"] = status
+	members["detail"] = members["error"]
+	members["instance"] = instance
+	delete(members, "error")
+	converted, err := json.Marshal(members)
+	if err != nil {
+		return nil, false
+	}
+	return converted, true
+}
+
+// problemType maps an error code to its problem type URI under
+// PROBLEM_TYPE_BASE, e.g. STUDENT_NOT_FOUND to
+// urn:student-api:problem:student-not-found
+func problemType(code string) string {
+	return cfg.ProblemTypeBase + strings.ToLower(strings.ReplaceAll(code, "_", "-"))
+}
+
+// problemTitle spells out an error code as the fixed summary of its problem
+// type, e.g. STUDENT_NOT_FOUND as "Student not found" and INVALID_JSON as
+// "Invalid JSON"
+func problemTitle(code string) string {
+	words := strings.Split(strings.ToLower(code), "_")
+	for i, word := range words {
+		if word == "json" {
+			words[i] = "JSON"
+		}
+	}
+	words[0] = strings.ToUpper(words[0][:1]) + words[0][1:]
+	return strings.Join(words, " ")
+}
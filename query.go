@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tagFilter matches students with tag Key, holding Value unless AnyValue
+type tagFilter struct {
+	Key      string
+	Value    string
+	AnyValue bool
+}
+
+// listQuery holds the filters and pagination accepted by the list endpoint
+type listQuery struct {
+	// Q and Classes hold search keys, see searchKey
+	Q       string
+	Classes []string // any of
+	Subject string
+	// NoSubject keeps only students taking no subjects
+	NoSubject bool
+	Tags      []tagFilter // all of
+	Limit     int         // 0 means no limit
+	Offset    int
+
+	IncludeDeleted bool
+	// ModifiedSince keeps students updated at or after it, nil keeps all
+	ModifiedSince *time.Time
+}
+
+// parseListQuery reads the list endpoint's query parameters, returning an
+// error describing the first malformed value.
+//
+// class may repeat (?class=10A&class=10B) and every value may itself be a
+// comma-separated list; all of them are ORed together. tag may repeat too,
+// as key:value or a bare key matching any value, and every one must match.
+// Every other parameter is single-valued, so repeating it with different
+// values is rejected as ambiguous.
+func parseListQuery(values url.Values) (listQuery, error) {
+	var q listQuery
+	for _, raw := range values["class"] {
+		for _, class := range parseList(raw) {
+			q.Classes = append(q.Classes, searchKey(class))
+		}
+	}
+
+	for _, raw := range values["tag"] {
+		key, value, hasValue := strings.Cut(raw, ":")
+		if !tagKeyPattern.MatchString(key) {
+			return q, fmt.Errorf("invalid tag: must be key:value or key, with key matching %s", tagKeyPattern)
+		}
+		q.Tags = append(q.Tags, tagFilter{Key: key, Value: value, AnyValue: !hasValue})
+	}
+
+	var err error
+	if q.Q, err = singleValue(values, "q"); err != nil {
+		return q, err
+	}
+	q.Q = searchKey(strings.TrimSpace(q.Q))
+	if q.Subject, err = singleValue(values, "subject"); err != nil {
+		return q, err
+	}
+	if q.NoSubject, err = parseBool(values, "no_subject"); err != nil {
+		return q, err
+	}
+	if q.NoSubject && q.Subject != "" {
+		return q, fmt.Errorf("invalid no_subject: contradicts subject")
+	}
+	if q.IncludeDeleted, err = parseBool(values, "include_deleted"); err != nil {
+		return q, err
+	}
+	if q.ModifiedSince, err = parseTimestamp(values, "modified_since"); err != nil {
+		return q, err
+	}
+	if q.Limit, err = parseNonNegative(values, "limit"); err != nil {
+		return q, err
+	}
+	if q.Offset, err = parseNonNegative(values, "offset"); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+// pageSizeHeader lets a client choose its own default page size, so it need
+// not add limit to every call
+const pageSizeHeader = "X-Page-Size"
+
+// applyPageSize sets q.Limit from X-Page-Size when the query gave no limit.
+// The header takes the same values as limit, 0 meaning no limit, and
+// MAX_LIST_RESULTS caps it the same way. Responses depend on it, so callers
+// must add it to Vary.
+func applyPageSize(r *http.Request, q *listQuery) error {
+	raw := strings.TrimSpace(r.Header.Get(pageSizeHeader))
+	if raw == "" || r.URL.Query().Has("limit") {
+		return nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid %s header: must be a non-negative integer", pageSizeHeader)
+	}
+	q.Limit = n
+	return nil
+}
+
+// singleValue returns the value of a parameter that may appear at most once.
+// Repeats carrying the same value are harmless and accepted.
+func singleValue(values url.Values, key string) (string, error) {
+	all := values[key]
+	if len(all) == 0 {
+		return "", nil
+	}
+	for _, value := range all[1:] {
+		if value != all[0] {
+			return "", fmt.Errorf("invalid %s: must not be repeated with different values", key)
+		}
+	}
+	return all[0], nil
+}
+
+// parseList splits a comma-separated parameter into trimmed, lowercased
+// values, dropping empty ones
+func parseList(raw string) []string {
+	var list []string
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+func parseNonNegative(values url.Values, key string) (int, error) {
+	raw, err := singleValue(values, key)
+	if err != nil || raw == "" {
+		return 0, err
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s: must be a non-negative integer", key)
+	}
+	return n, nil
+}
+
+// parseTimestamp reads an RFC 3339 timestamp such as 2024-05-01T10:00:00Z
+func parseTimestamp(values url.Values, key string) (*time.Time, error) {
+	raw, err := singleValue(values, key)
+	if err != nil || raw == "" {
+		return nil, err
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: must be an RFC 3339 timestamp", key)
+	}
+	return &t, nil
+}
+
+// includes reports whether the query covers a student's deletion state.
+// Incremental syncs with modified_since also get students deleted since then,
+// recognizable by their deleted_at, so clients can apply the deletions.
+func (q listQuery) includes(student Student) bool {
+	return !student.IsDeleted || q.IncludeDeleted || q.ModifiedSince != nil
+}
+
+// filtered reports whether the query narrows or widens the default set of
+// non-deleted students, as opposed to only paginating it
+func (q listQuery) filtered() bool {
+	return q.Q != "" || len(q.Classes) > 0 || q.Subject != "" || q.NoSubject || len(q.Tags) > 0 || q.IncludeDeleted || q.ModifiedSince != nil
+}
+
+func parseBool(values url.Values, key string) (bool, error) {
+	raw, err := singleValue(values, key)
+	if err != nil || raw == "" {
+		return false, err
+	}
+
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: must be a boolean", key)
+	}
+	return b, nil
+}
+
+// matches reports whether a student satisfies the filters. Class matches any
+// of the listed classes and q matches any text field, both compared as
+// search keys. Subject must match one of the student's subjects exactly under
+// strict matching, or as a search key under normalized matching. Tags match
+// exactly, keys and values alike.
+func (q listQuery) matches(student Student) bool {
+	if q.ModifiedSince != nil && student.UpdatedAt.Before(*q.ModifiedSince) {
+		return false
+	}
+	if len(q.Classes) > 0 && !slices.Contains(q.Classes, searchKey(student.Class)) {
+		return false
+	}
+	if q.Subject != "" && !slices.ContainsFunc(student.Subjects, q.subjectMatches) {
+		return false
+	}
+	if q.NoSubject && len(student.Subjects) > 0 {
+		return false
+	}
+	for _, tag := range q.Tags {
+		if value, ok := student.Tags[tag.Key]; !ok || !tag.AnyValue && value != tag.Value {
+			return false
+		}
+	}
+	if q.Q != "" &&
+		!strings.Contains(searchKey(student.Name), q.Q) &&
+		!strings.Contains(searchKey(student.Class), q.Q) &&
+		!slices.ContainsFunc(student.Subjects, func(s string) bool { return strings.Contains(searchKey(s), q.Q) }) {
+		return false
+	}
+	return true
+}
+
+func (q listQuery) subjectMatches(subject string) bool {
+	if cfg.SearchMatching == searchStrict {
+		return subject == q.Subject
+	}
+	return searchKey(subject) == searchKey(q.Subject)
+}
+
+// paginate returns the window of result selected by offset and limit
+func (q listQuery) paginate(result []Student) []Student {
+	if q.Offset >= len(result) {
+		return nil
+	}
+	result = result[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(result) {
+		result = result[:q.Limit]
+	}
+	return result
+}
+
+// Orders selectable via DEFAULT_SORT for the list endpoint and the store's
+// cached snapshot. Ties, and students without a name, fall back to
+// enrollment number, so every order is total and repeated calls agree.
+const (
+	sortByEnrollment = "enrollment_number"
+	sortByName       = "name"
+	sortByCreated    = "created_at"
+)
+
+// sortStudents orders students in place by DEFAULT_SORT
+func sortStudents(students []Student) {
+	sort.Slice(students, func(i, j int) bool {
+		a, b := students[i], students[j]
+		switch cfg.DefaultSort {
+		case sortByName:
+			if ka, kb := searchKey(a.Name), searchKey(b.Name); ka != kb {
+				return ka < kb
+			}
+		case sortByCreated:
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+		}
+		return a.EnrollmentNumber < b.EnrollmentNumber
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Name the built-in in-memory backend is registered under
+const memoryStoreName = "memory"
+
+// Store is the persistence layer every handler reads and writes students
+// through. Implementations must give the guarantees studentStore documents:
+// View sees a consistent snapshot, Update and Exclusive serialize writers to
+// what they cover, and a callback returning an error leaves the store as it
+// was. Writes should also be passed to history.record so /history and
+// /revert keep working.
+type Store interface {
+	// Get returns the stored record for id, including soft-deleted ones
+	Get(id string) (Student, bool)
+	// Range calls fn for every stored record until fn returns false
+	Range(fn func(Student) bool)
+	// View runs fn with read access to a consistent snapshot
+	View(fn func(tx storeTx))
+	// Update runs fn with write access to just the record for id
+	Update(id string, fn func(tx storeTx) error) error
+	// Exclusive runs fn with sole access to every record
+	Exclusive(fn func(tx storeTx) error) error
+	// Replace swaps the whole contents for records
+	Replace(records []Student)
+	// Active returns every non-deleted student in sortStudents order
+	Active() []Student
+	// Generation returns a counter that changes on every write, so caches
+	// can tell when they are stale
+	Generation() uint64
+}
+
+// storeTx is the access a Store hands its callbacks. Transactions from
+// Update may only touch the id they were opened for.
+type storeTx interface {
+	Get(id string) (Student, bool)
+	Put(student Student)
+	// Delete removes the record for id outright, unlike a soft delete
+	Delete(id string)
+	Range(fn func(Student) bool)
+}
+
+// storeFactories maps STORE names to the backends that can be opened
+var storeFactories = map[string]func(Config) (Store, error){
+	memoryStoreName: func(Config) (Store, error) { return newStudentStore(), nil },
+}
+
+// RegisterStore makes a storage backend selectable with STORE=name. Call it
+// from an init function in a file added to this package; like
+// database/sql.Register it panics on a nil factory or a name already taken.
+func RegisterStore(name string, factory func(Config) (Store, error)) {
+	if factory == nil {
+		panic("RegisterStore: nil factory for " + name)
+	}
+	if _, taken := storeFactories[name]; taken {
+		panic("RegisterStore: store " + name + " registered twice")
+	}
+	storeFactories[name] = factory
+}
+
+// mustOpenStore opens the backend named by cfg.Store, exiting with a clear
+// message when it is unknown or fails to open
+func mustOpenStore() {
+	factory, ok := storeFactories[cfg.Store]
+	if !ok {
+		names := make([]string, 0, len(storeFactories))
+		for name := range storeFactories {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		err := fmt.Errorf("invalid configuration: STORE=%q: must be one of %s", cfg.Store, strings.Join(names, ", "))
+		fmt.Fprintln(os.Stderr, err)
+		ErrorLogger.Fatalln(err)
+	}
+
+	opened, err := factory(cfg)
+	if err != nil {
+		err = fmt.Errorf("failed to open %s store: %w", cfg.Store, err)
+		fmt.Fprintln(os.Stderr, err)
+		ErrorLogger.Fatalln(err)
+	}
+	store = opened
+	InfoLogger.Printf("Using %s store", cfg.Store)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeStore is a third-party backend as RegisterStore sees one: it serves
+// a record it was opened with and counts the reads handlers send it
+type fakeStore struct {
+	*studentStore
+	gets atomic.Int32
+}
+
+func (f *fakeStore) Get(id string) (Student, bool) {
+	f.gets.Add(1)
+	return f.studentStore.Get(id)
+}
+
+func TestRegisteredStoreServesHandlers(t *testing.T) {
+	var opened *fakeStore
+	RegisterStore("fake", func(c Config) (Store, error) {
+		opened = &fakeStore{studentStore: newStudentStore()}
+		opened.Exclusive(func(tx storeTx) error {
+			tx.Put(Student{EnrollmentNumber: "F1", Name: "From the fake store", Age: 10, Class: "5A"})
+			return nil
+		})
+		return opened, nil
+	})
+	t.Cleanup(func() { delete(storeFactories, "fake") })
+
+	srv := newTestServer(t, func(c *Config) { c.Store = "fake" })
+	mustOpenStore()
+	if store != Store(opened) {
+		t.Fatalf("mustOpenStore selected %T, want the registered fake store", store)
+	}
+
+	resp, body := doJSON(t, srv, http.MethodGet, "/student/v1/students/F1", nil)
+	if resp.StatusCode != http.StatusOK || !bytes.Contains(body, []byte("From the fake store")) {
+		t.Fatalf("GET from the fake store: status %d: %s", resp.StatusCode, body)
+	}
+	if opened.gets.Load() == 0 {
+		t.Error("the handler did not read through the registered store")
+	}
+
+	id := mustCreate(t, srv, map[string]interface{}{"name": "Ben", "age": 11, "class": "5A"})
+	if _, exists := opened.studentStore.Get(id); !exists {
+		t.Error("a create did not write to the registered store")
+	}
+}
+
+func TestRegisterStorePanics(t *testing.T) {
+	for name, register := range map[string]func(){
+		"nil factory": func() { RegisterStore("nil", nil) },
+		"taken name":  func() { RegisterStore(memoryStoreName, storeFactories[memoryStoreName]) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("RegisterStore did not panic")
+				}
+			}()
+			register()
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Response shapes for list endpoints, chosen per request via the Accept
+// header's profile parameter (e.g. `application/json; profile="envelope"`)
+// or server-wide via LIST_ENVELOPE
+const (
+	profileBare     = "bare"
+	profileEnvelope = "envelope"
+)
+
+// wantsEnvelope reports whether a list response should be wrapped as
+// {"data": [...]} rather than returned as a bare array
+func wantsEnvelope(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		_, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch params["profile"] {
+		case profileEnvelope:
+			return true
+		case profileBare:
+			return false
+		}
+	}
+	return cfg.ListEnvelope
+}
+
+// writeJSON encodes v before committing status, so a value that fails to
+// encode becomes a 500 rather than a truncated body behind a 200. A failed
+// write, usually a client that went away, can only be logged.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := encodeJSON(v)
+	if err != nil {
+		ErrorLogger.Printf("Failed to encode response: %v", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Internal server error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		ErrorLogger.Printf("Failed to write response: %v", err)
+	}
+}
+
+// listResponse shapes a list of items according to wantsEnvelope
+func listResponse[T any](r *http.Request, items []T) interface{} {
+	if !wantsEnvelope(r) {
+		return items
+	}
+	if items == nil {
+		items = []T{}
+	}
+	return map[string][]T{"data": items}
+}
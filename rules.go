@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// validationRule is one deployment-specific constraint from VALIDATION_RULES,
+// checked on top of the built-in ones by validateStudent and by every handler
+// that changes part of a student. In and Pattern apply to text fields, Min
+// and Max to age; a rule may combine several.
+type validationRule struct {
+	Field   string   `json:"field"`
+	In      []string `json:"in,omitempty"`
+	Min     *int     `json:"min,omitempty"`
+	Max     *int     `json:"max,omitempty"`
+	Pattern string   `json:"pattern,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// Fields rules may constrain, by whether they hold text. Rules on subjects
+// apply to every subject.
+var ruleFields = map[string]bool{
+	"name":     true,
+	"class":    true,
+	"subjects": true,
+	"age":      false,
+}
+
+// parseValidationRules parses a VALIDATION_RULES value, a JSON array such as
+// [{"field":"class","in":["10A","10B"]},{"field":"age","min":5,"max":18}],
+// rejecting rules that name an unknown field, constrain nothing or use a
+// constraint the field's type doesn't support
+func parseValidationRules(raw string) ([]validationRule, error) {
+	var rules []validationRule
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("must be a JSON array of rules: %v", err)
+	}
+
+	for i, rule := range rules {
+		text, known := ruleFields[rule.Field]
+		switch {
+		case !known:
+			return nil, fmt.Errorf("rule %d: unknown field %q", i, rule.Field)
+		case rule.In == nil && rule.Min == nil && rule.Max == nil && rule.Pattern == "":
+			return nil, fmt.Errorf("rule %d: must set in, min, max or pattern", i)
+		case text && (rule.Min != nil || rule.Max != nil):
+			return nil, fmt.Errorf("rule %d: min and max only apply to age", i)
+		case !text && (rule.In != nil || rule.Pattern != ""):
+			return nil, fmt.Errorf("rule %d: in and pattern only apply to text fields", i)
+		case rule.Min != nil && rule.Max != nil && *rule.Min > *rule.Max:
+			return nil, fmt.Errorf("rule %d: min is above max", i)
+		}
+		if rule.Pattern != "" {
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: invalid pattern: %v", i, err)
+			}
+			rules[i].pattern = re
+		}
+	}
+	return rules, nil
+}
+
+// checkRules appends a field error for every VALIDATION_RULES constraint
+// student breaks. Empty text is left to the built-in checks, so rules never
+// make an optional field required.
+func checkRules(errs []fieldError, student Student) []fieldError {
+	for _, rule := range cfg.ValidationRules {
+		switch rule.Field {
+		case "name":
+			errs = rule.checkText(errs, "name", student.Name)
+		case "class":
+			errs = rule.checkText(errs, "class", student.Class)
+		case "subjects":
+			for i, subject := range student.Subjects {
+				errs = rule.checkText(errs, fmt.Sprintf("subjects[%d]", i), subject)
+			}
+		case "age":
+			field := "age"
+			if student.DateOfBirth != "" {
+				field = "date_of_birth"
+			}
+			age := student.currentAge()
+			if rule.Min != nil && age < *rule.Min {
+				errs = append(errs, newFieldError(field, fieldBelowMinimum, *rule.Min))
+			}
+			if rule.Max != nil && age > *rule.Max {
+				errs = append(errs, newFieldError(field, fieldAboveMaximum, *rule.Max))
+			}
+		}
+	}
+	return errs
+}
+
+func (rule validationRule) checkText(errs []fieldError, field, value string) []fieldError {
+	if value == "" {
+		return errs
+	}
+	if rule.In != nil && !slices.Contains(rule.In, value) {
+		errs = append(errs, newFieldError(field, fieldNotAllowed, strings.Join(rule.In, ", ")))
+	}
+	if rule.pattern != nil && !rule.pattern.MatchString(value) {
+		errs = append(errs, newFieldError(field, fieldPatternMismatch, rule.Pattern))
+	}
+	return errs
+}
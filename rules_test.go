@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// TestRulesOnPartialUpdates checks VALIDATION_RULES hold on the handlers that
+// change part of a student rather than taking a whole payload
+func TestRulesOnPartialUpdates(t *testing.T) {
+	rules, err := parseValidationRules(`[{"field":"class","in":["5A","6A"]},{"field":"subjects","in":["Math","Art"]}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newTestServer(t, func(c *Config) { c.ValidationRules = rules })
+	id := mustCreate(t, srv, map[string]interface{}{"name": "Ann", "age": 10, "class": "5A", "subjects": []string{"Math"}})
+
+	for _, tc := range []struct{ method, path, body string }{
+		{http.MethodPost, "/student/v1/classes/5A/promote", `{"to_class":"7B"}`},
+		{http.MethodPost, "/student/v1/students/" + id + "/subjects", `{"subject":"Music"}`},
+	} {
+		resp, body := doJSON(t, srv, tc.method, tc.path, tc.body)
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("%s %s: status %d: %s, want 422", tc.method, tc.path, resp.StatusCode, body)
+		}
+	}
+
+	resp, body := doJSON(t, srv, http.MethodPost, "/student/v1/students/assign-subject", map[string]interface{}{"ids": []string{id}, "subject": "Music"})
+	var results []bulkItemResult
+	json.Unmarshal(body, &results)
+	if resp.StatusCode != http.StatusOK || len(results) != 1 || results[0].Code != codeValidationFailed {
+		t.Errorf("assign-subject: status %d: %s, want the item failed with %s", resp.StatusCode, body, codeValidationFailed)
+	}
+
+	if student, _ := store.Get(id); student.Class != "5A" || !slices.Equal(student.Subjects, []string{"Math"}) {
+		t.Errorf("after rejected changes: class %q subjects %q, want 5A and Math", student.Class, student.Subjects)
+	}
+
+	// A student stored before the rules tightened can't be changed piecemeal
+	// without being brought into line
+	stale, _ := store.Get(id)
+	stale.Subjects = []string{"Music"}
+	store.Update(id, func(tx storeTx) error {
+		tx.Put(stale)
+		return nil
+	})
+	resp, body = doJSON(t, srv, http.MethodPut, "/student/v1/students/"+id+"/tags/team", `{"value":"red"}`)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("setting a tag on a student breaking the rules: status %d: %s, want 422", resp.StatusCode, body)
+	}
+
+	resp, body = doJSON(t, srv, http.MethodPost, "/student/v1/classes/5A/promote", `{"to_class":"6A"}`)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("promoting a student breaking the rules: status %d: %s, want 422", resp.StatusCode, body)
+	}
+	resp, body = doJSON(t, srv, http.MethodDelete, "/student/v1/students/"+id+"/subjects?subject=Music", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("removing the subject that breaks the rules: status %d: %s, want 200", resp.StatusCode, body)
+	}
+	resp, body = doJSON(t, srv, http.MethodPost, "/student/v1/classes/5A/promote", `{"to_class":"6A"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("promoting within the rules: status %d: %s, want 200", resp.StatusCode, body)
+	}
+}
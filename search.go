@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Text matching modes for search and filters, selectable via SEARCH_MATCHING
+//   - normalized: values and query terms are compared as Unicode canonical
+//     caseless matches, with whitespace collapsed, so "José" matches however
+//     its accent is encoded, "STRASSE" matches "Straße" and "  Ann  Lee "
+//     matches "ann lee"
+//   - strict: plain lowercased comparison of the raw text
+const (
+	searchNormalized = "normalized"
+	searchStrict     = "strict"
+)
+
+// searchKey returns the form of s that search and filters compare
+func searchKey(s string) string {
+	if cfg.SearchMatching == searchStrict {
+		return strings.ToLower(s)
+	}
+	// Full case folding between canonical decompositions, per Unicode's
+	// canonical caseless matching, then recomposed so keys stay compact
+	collapsed := strings.Join(strings.Fields(s), " ")
+	return norm.NFC.String(cases.Fold().String(norm.NFD.String(collapsed)))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSearchKeyNormalized(t *testing.T) {
+	saved := cfg
+	cfg.SearchMatching = searchNormalized
+	t.Cleanup(func() { cfg = saved })
+
+	for _, tc := range []struct {
+		name string
+		a, b string
+	}{
+		{"ascii case", "Ann Lee", "ANN LEE"},
+		{"kelvin sign", "\u212Aelvin", "kelvin"},
+		{"kelvin sign upper", "\u212AELVIN", "KELVIN"},
+		{"long s", "\u017Fam", "sam"},
+		{"long s upper", "\u017FAM", "SAM"},
+		{"sharp s", "Stra\u00DFe", "STRASSE"},
+		{"final sigma", "\u039F\u0394\u039F\u03A3", "\u03BF\u03B4\u03BF\u03C2"},
+		{"final sigma form", "\u03BF\u03B4\u03BF\u03C2", "\u03BF\u03B4\u03BF\u03C3"},
+		{"composed and decomposed", "Jos\u00E9", "Jose\u0301"},
+		{"marks out of order", "\u1EAD", "a\u0302\u0323"},
+		{"decomposed upper", "JOSE\u0301", "jos\u00E9"},
+		{"hangul", "\uD55C", "\u1112\u1161\u11AB"},
+		{"whitespace", "  Ann \t Lee ", "ann lee"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if ka, kb := searchKey(tc.a), searchKey(tc.b); ka != kb {
+				t.Errorf("searchKey(%q) = %q, searchKey(%q) = %q, want them equal", tc.a, ka, tc.b, kb)
+			}
+		})
+	}
+
+	for _, tc := range []struct {
+		name string
+		a, b string
+	}{
+		{"different letters", "Ann", "Anne"},
+		{"accent matters", "Jos\u00E9", "Jose"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if ka, kb := searchKey(tc.a), searchKey(tc.b); ka == kb {
+				t.Errorf("searchKey(%q) = searchKey(%q) = %q, want them different", tc.a, tc.b, ka)
+			}
+		})
+	}
+}
+
+// TestSearchKeyAgreesWithEqualFold checks every pair strings.EqualFold calls
+// equal also shares a search key, including the Kelvin and Angstrom signs
+// and the long s that fold onto ASCII letters
+func TestSearchKeyAgreesWithEqualFold(t *testing.T) {
+	saved := cfg
+	cfg.SearchMatching = searchNormalized
+	t.Cleanup(func() { cfg = saved })
+
+	for _, pair := range [][2]string{{"k", "\u212A"}, {"K", "\u212A"}, {"s", "\u017F"}, {"S", "\u017F"}, {"\u00E5", "\u212B"}, {"\u03B8", "\u03D1"}, {"\u00B5", "\u03BC"}} {
+		if !strings.EqualFold(pair[0], pair[1]) {
+			t.Fatalf("test data: %q and %q are not EqualFold", pair[0], pair[1])
+		}
+		if a, b := searchKey(pair[0]), searchKey(pair[1]); a != b {
+			t.Errorf("searchKey(%q) = %q, searchKey(%q) = %q, want them equal", pair[0], a, pair[1], b)
+		}
+	}
+}
+
+func TestSearchMatchingThroughTheAPI(t *testing.T) {
+	srv := newTestServer(t, nil)
+	mustCreate(t, srv, map[string]interface{}{"name": "Kate Stra\u00DFe", "age": 10, "class": "5A"})
+
+	for _, q := range []string{"kate", "KATE", "strasse", "e  stra"} {
+		resp, body := doJSON(t, srv, http.MethodGet, "/student/v1/students?q="+url.QueryEscape(q), nil)
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "Kate") {
+			t.Errorf("q=%s: status %d: %s, want the student", q, resp.StatusCode, body)
+		}
+	}
+}
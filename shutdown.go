@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Tracks requests currently being served so shutdown can report on them
+var (
+	inFlightMu sync.Mutex
+	inFlight   = make(map[*http.Request]string)
+)
+
+// inFlightMiddleware records every request for the lifetime of its handler
+func inFlightMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlightMu.Lock()
+		inFlight[r] = fmt.Sprintf("%s %s", r.Method, r.URL.Path)
+		inFlightMu.Unlock()
+
+		defer func() {
+			inFlightMu.Lock()
+			delete(inFlight, r)
+			inFlightMu.Unlock()
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// activeRequests returns a description of each request still being served
+func activeRequests() []string {
+	inFlightMu.Lock()
+	defer inFlightMu.Unlock()
+
+	active := make([]string, 0, len(inFlight))
+	for _, desc := range inFlight {
+		active = append(active, desc)
+	}
+	return active
+}
+
+// serveWithGracefulShutdown runs srv until SIGINT/SIGTERM, then gives
+// in-flight requests up to cfg.ShutdownTimeout to drain before force-closing
+func serveWithGracefulShutdown(srv *http.Server) {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			ErrorLogger.Fatalf("Failed to start server: %v", err)
+		}
+		return
+	case s := <-sig:
+		InfoLogger.Printf("Received %v, shutting down with %d in-flight requests (timeout %v)", s, len(activeRequests()), cfg.ShutdownTimeout)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		active := activeRequests()
+		ErrorLogger.Printf("Shutdown timeout elapsed, force-closing %d in-flight requests: %v", len(active), active)
+		srv.Close()
+		return
+	}
+	InfoLogger.Println("All in-flight requests drained, server stopped")
+}
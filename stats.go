@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// setStatsCacheControl lets dashboards reuse a stats response for
+// STATS_MAX_AGE. Stats carry content ETags, so once that lapses a poll
+// revalidates with If-None-Match and gets 304 until students change.
+func setStatsCacheControl(w http.ResponseWriter) {
+	if cfg.StatsMaxAge > 0 {
+		w.Header().Set("Cache-Control", "max-age="+strconv.Itoa(int(cfg.StatsMaxAge/time.Second)))
+	}
+}
+
+// subjectStats summarizes the students taking one subject
+type subjectStats struct {
+	Subject    string  `json:"subject"`
+	Count      int     `json:"count"`
+	AverageAge float64 `json:"average_age"`
+}
+
+// GET /student/v1/stats/by-subject - Count and average age per subject,
+// computed in one pass over non-deleted students and sorted by subject.
+// Students without subjects are left out.
+func getStatsBySubject(w http.ResponseWriter, r *http.Request) {
+	totals := make(map[string]*subjectStats)
+	ageSums := make(map[string]int)
+	ok := scanStudents(r, func(student Student) {
+		if student.IsDeleted {
+			return
+		}
+		// A student counts once towards each subject they take
+		for _, subject := range student.Subjects {
+			stats, ok := totals[subject]
+			if !ok {
+				stats = &subjectStats{Subject: subject}
+				totals[subject] = stats
+			}
+			stats.Count++
+			ageSums[subject] += student.currentAge()
+		}
+	})
+	if !ok {
+		return
+	}
+
+	result := make([]subjectStats, 0, len(totals))
+	for subject, stats := range totals {
+		stats.AverageAge = float64(ageSums[subject]) / float64(stats.Count)
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Subject < result[j].Subject
+	})
+
+	InfoLogger.Printf("Retrieved stats by subject")
+	setStatsCacheControl(w)
+	writeJSONWithETag(w, r, listResponse(r, result))
+}
+
+// GET /student/v1/stats/age-histogram?bucket=2 - Count non-deleted students
+// per age bucket of the given width (default 1), e.g. {"13-14":5,"15-16":10}.
+// Buckets start at minAge; with width 1 they are keyed by the bare age. Empty
+// buckets are left out. Keys are written in age order.
+func getAgeHistogram(w http.ResponseWriter, r *http.Request) {
+	width := 1
+	raw, err := singleValue(r.URL.Query(), "bucket")
+	if err == nil && raw != "" {
+		width, err = strconv.Atoi(raw)
+		if err != nil || width < 1 {
+			err = fmt.Errorf("invalid bucket: must be a positive integer")
+		}
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	histogram := ageHistogram{width: width, counts: make(map[int]int)}
+	ok := scanStudents(r, func(student Student) {
+		if !student.IsDeleted {
+			histogram.counts[bucketStart(student.currentAge(), width)]++
+		}
+	})
+	if !ok {
+		return
+	}
+
+	InfoLogger.Printf("Retrieved age histogram with bucket width %d", width)
+	setStatsCacheControl(w)
+	markDataKeyed(w)
+	writeJSONWithETag(w, r, histogram)
+}
+
+// ageHistogram counts students per bucket, keyed by the bucket's first age
+type ageHistogram struct {
+	width  int
+	counts map[int]int
+}
+
+// MarshalJSON writes the buckets in age order. A map[string]int would sort
+// its keys as strings, putting "10" before "9".
+func (h ageHistogram) MarshalJSON() ([]byte, error) {
+	starts := make([]int, 0, len(h.counts))
+	for start := range h.counts {
+		starts = append(starts, start)
+	}
+	sort.Ints(starts)
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, start := range starts {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(&buf, "%q:%d", bucketLabel(start, h.width), h.counts[start])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// bucketStart is the first age of the bucket of the given width that age
+// falls in
+func bucketStart(age, width int) int {
+	if width == 1 {
+		return age
+	}
+	return minAge + (age-minAge)/width*width
+}
+
+// bucketLabel names a bucket by its ages: the bare age with width 1
+func bucketLabel(start, width int) string {
+	if width == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d-%d", start, start+width-1)
+}
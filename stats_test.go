@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAgeHistogramOrder(t *testing.T) {
+	for _, naming := range []string{namingSnake, namingCamel} {
+		t.Run(naming, func(t *testing.T) {
+			srv := newTestServer(t, func(c *Config) { c.JSONNaming = naming })
+			for _, age := range []int{10, 2, 9, 11, 9} {
+				mustCreate(t, srv, map[string]interface{}{"name": "Student", "age": age, "class": "5A"})
+			}
+
+			for _, tc := range []struct{ query, want string }{
+				{"", `{"2":1,"9":2,"10":1,"11":1}`},
+				{"?bucket=5", `{"1-5":1,"6-10":3,"11-15":1}`},
+			} {
+				resp, body := doJSON(t, srv, http.MethodGet, "/student/v1/stats/age-histogram"+tc.query, nil)
+				if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != tc.want {
+					t.Errorf("histogram%s: status %d: %s, want %s", tc.query, resp.StatusCode, body, tc.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"errors"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Number of independently locked partitions of the store
+const shardCount = 32
+
+var errStudentNotFound = errors.New("student not found")
+
+type shard struct {
+	mu       sync.RWMutex
+	students map[string]Student
+}
+
+// studentStore is the built-in "memory" Store, split into shards keyed by a hash
+// of the enrollment number so operations on different students don't
+// contend for the same lock.
+//
+// Locking invariants:
+//   - single-key operations (Get, Update) hold mu shared plus the one shard
+//     lock for their key, so they run in parallel across shards
+//   - Range and View hold mu shared plus every shard's read lock, taken in
+//     index order, so they see a consistent snapshot while still allowing
+//     other readers
+//   - Exclusive holds mu exclusively and needs no shard locks; use it for
+//     anything that must check or change several keys atomically
+//   - a Student is stored by value, so copies handed out may be inspected
+//     after the lock is released, and values behind its pointers and slices
+//     (DeletedAt, Subjects) are never mutated once stored, only replaced
+//   - callbacks run with locks held and must not call back into the store
+//   - a transaction whose callback returns an error is rolled back, so
+//     multi-record writes are all-or-nothing
+type studentStore struct {
+	mu     sync.RWMutex
+	shards [shardCount]*shard
+
+	// gen is bumped on every write; active caches the sorted list of
+	// non-deleted students as of a generation
+	gen    atomic.Uint64
+	active atomic.Pointer[activeSnapshot]
+}
+
+type activeSnapshot struct {
+	gen      uint64
+	students []Student
+}
+
+// store is the backend selected by STORE, see mustOpenStore
+var store Store = newStudentStore()
+
+func newStudentStore() *studentStore {
+	s := &studentStore{}
+	for i := range s.shards {
+		s.shards[i] = &shard{students: make(map[string]Student)}
+	}
+	return s
+}
+
+// storeKey normalizes an enrollment number into the key records are stored
+// and looked up under. With ENROLLMENT_CASE_INSENSITIVE set, "ABC123" and
+// "abc123" share a key, while the record keeps the casing it was created with.
+func storeKey(id string) string {
+	if cfg.EnrollmentCaseInsensitive {
+		return strings.ToLower(id)
+	}
+	return id
+}
+
+// shardFor expects a key already normalized by storeKey
+func (s *studentStore) shardFor(id string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return s.shards[h.Sum32()%shardCount]
+}
+
+// Get returns the stored record for id, including soft-deleted ones
+func (s *studentStore) Get(id string) (Student, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key := storeKey(id)
+	sh := s.shardFor(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	student, exists := sh.students[key]
+	return student, exists
+}
+
+// Range calls fn for every stored record until fn returns false
+func (s *studentStore) Range(fn func(Student) bool) {
+	s.View(func(tx storeTx) {
+		tx.Range(fn)
+	})
+}
+
+// View runs fn with read access to a consistent snapshot of the whole store.
+// The transaction must not be written to.
+func (s *studentStore) View(fn func(tx storeTx)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		defer sh.mu.RUnlock()
+	}
+	fn(memoryTx{s: s})
+}
+
+// Update runs fn with write access to the shard holding id. The transaction
+// may only read or write that id; use Exclusive for anything wider.
+func (s *studentStore) Update(id string, fn func(tx storeTx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sh := s.shardFor(storeKey(id))
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	return run(memoryTx{s: s, scoped: true}, fn)
+}
+
+// Exclusive runs fn with sole access to the whole store
+func (s *studentStore) Exclusive(fn func(tx storeTx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return run(memoryTx{s: s}, fn)
+}
+
+// run calls fn inside tx, undoing its writes in reverse order if it fails and
+// recording them in the history if it succeeds
+func run(tx memoryTx, fn func(tx storeTx) error) error {
+	var journal []undoEntry
+	tx.journal = &journal
+
+	err := fn(tx)
+	if err == nil {
+		// Record each key written once, at its committed value
+		recorded := make(map[string]bool, len(journal))
+		for _, entry := range journal {
+			if !recorded[entry.key] {
+				recorded[entry.key] = true
+				student, exists := tx.s.shardFor(entry.key).students[entry.key]
+				history.record(entry.key, student, exists)
+			}
+		}
+	} else {
+		for i := len(journal) - 1; i >= 0; i-- {
+			entry := journal[i]
+			students := tx.s.shardFor(entry.key).students
+			if entry.existed {
+				students[entry.key] = entry.previous
+			} else {
+				delete(students, entry.key)
+			}
+		}
+		if len(journal) > 0 {
+			tx.s.gen.Add(1)
+		}
+	}
+	return err
+}
+
+// Replace atomically swaps the store's contents for records. The previous
+// contents' history goes with them.
+func (s *studentStore) Replace(records []Student) {
+	fresh := newStudentStore()
+	for _, student := range records {
+		key := storeKey(student.EnrollmentNumber)
+		fresh.shardFor(key).students[key] = withSubjects(student)
+	}
+
+	s.mu.Lock()
+	s.shards = fresh.shards
+	s.gen.Add(1)
+	history.reset()
+	s.mu.Unlock()
+}
+
+// Active returns every non-deleted student sorted by DEFAULT_SORT. The
+// result is cached until the next write, so repeated unfiltered list calls
+// skip the scan and sort. Callers must treat the slice as read-only.
+func (s *studentStore) Active() []Student {
+	if cached := s.active.Load(); cached != nil && cached.gen == s.gen.Load() {
+		return cached.students
+	}
+
+	// Read gen before scanning: a write racing the scan can only make the
+	// snapshot newer than its label, which just forces a rebuild next time
+	gen := s.gen.Load()
+	var result []Student
+	s.Range(func(student Student) bool {
+		if !student.IsDeleted {
+			result = append(result, student)
+		}
+		return true
+	})
+
+	sortStudents(result)
+	result = result[:len(result):len(result)]
+	s.active.Store(&activeSnapshot{gen: gen, students: result})
+	return result
+}
+
+// Generation returns a counter bumped on every write
+func (s *studentStore) Generation() uint64 {
+	return s.gen.Load()
+}
+
+// memoryTx is studentStore's storeTx, giving unsynchronized access to the
+// shards while the caller holds the locks taken by Update, View or Exclusive
+type memoryTx struct {
+	s *studentStore
+	// scoped transactions come from Update and only own a single shard
+	scoped bool
+	// journal records what each write replaced, for rollback
+	journal *[]undoEntry
+}
+
+// undoEntry is the state of one key before a transaction wrote it
+type undoEntry struct {
+	key      string
+	previous Student
+	existed  bool
+}
+
+// record journals the current value of key before it is overwritten
+func (tx memoryTx) record(key string) {
+	if tx.journal == nil {
+		return
+	}
+	previous, existed := tx.s.shardFor(key).students[key]
+	*tx.journal = append(*tx.journal, undoEntry{key: key, previous: previous, existed: existed})
+}
+
+func (tx memoryTx) Get(id string) (Student, bool) {
+	key := storeKey(id)
+	student, exists := tx.s.shardFor(key).students[key]
+	return student, exists
+}
+
+// Put stores student, normalizing its subjects with withSubjects
+func (tx memoryTx) Put(student Student) {
+	tx.s.gen.Add(1)
+	key := storeKey(student.EnrollmentNumber)
+	tx.record(key)
+	tx.s.shardFor(key).students[key] = withSubjects(student)
+}
+
+// Delete removes the record for id outright, unlike a soft delete
+func (tx memoryTx) Delete(id string) {
+	tx.s.gen.Add(1)
+	key := storeKey(id)
+	tx.record(key)
+	delete(tx.s.shardFor(key).students, key)
+}
+
+// Range iterates every record, so it is unavailable to scoped transactions
+func (tx memoryTx) Range(fn func(Student) bool) {
+	if tx.scoped {
+		panic("storeTx.Range called inside a single-key Update")
+	}
+	for _, sh := range tx.s.shards {
+		for _, student := range sh.students {
+			if !fn(student) {
+				return
+			}
+		}
+	}
+}
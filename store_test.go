@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestExclusiveRollsBackOnError injects a failure after a transaction has
+// overwritten, created and deleted records, and checks every write is undone
+func TestExclusiveRollsBackOnError(t *testing.T) {
+	s := newStudentStore()
+	s.Exclusive(func(tx storeTx) error {
+		tx.Put(Student{EnrollmentNumber: "A", Name: "Ann", Age: 10, Class: "5A"})
+		tx.Put(Student{EnrollmentNumber: "B", Name: "Ben", Age: 11, Class: "5A"})
+		return nil
+	})
+
+	errInjected := errors.New("injected mid-batch failure")
+	err := s.Exclusive(func(tx storeTx) error {
+		tx.Put(Student{EnrollmentNumber: "A", Name: "Changed", Age: 10, Class: "5A"})
+		tx.Put(Student{EnrollmentNumber: "C", Name: "Cat", Age: 12, Class: "5A"})
+		tx.Delete("B")
+		// Write the same key twice, so undo has to run in reverse order
+		tx.Put(Student{EnrollmentNumber: "A", Name: "Changed again", Age: 10, Class: "5A"})
+		return errInjected
+	})
+	if !errors.Is(err, errInjected) {
+		t.Fatalf("Exclusive returned %v, want the callback's error", err)
+	}
+
+	if a, _ := s.Get("A"); a.Name != "Ann" {
+		t.Errorf("A.Name = %q after rollback, want %q", a.Name, "Ann")
+	}
+	if _, exists := s.Get("B"); !exists {
+		t.Error("B was not restored after rollback")
+	}
+	if _, exists := s.Get("C"); exists {
+		t.Error("C survived a rolled-back transaction")
+	}
+	if got := len(s.Active()); got != 2 {
+		t.Errorf("len(Active()) = %d after rollback, want 2", got)
+	}
+}
+
+func TestUpdateRollsBackOnError(t *testing.T) {
+	s := newStudentStore()
+	s.Exclusive(func(tx storeTx) error {
+		tx.Put(Student{EnrollmentNumber: "A", Name: "Ann", Age: 10, Class: "5A"})
+		return nil
+	})
+
+	err := s.Update("A", func(tx storeTx) error {
+		tx.Put(Student{EnrollmentNumber: "A", Name: "Changed", Age: 10, Class: "5A"})
+		return errStudentNotFound
+	})
+	if !errors.Is(err, errStudentNotFound) {
+		t.Fatalf("Update returned %v, want the callback's error", err)
+	}
+	if a, _ := s.Get("A"); a.Name != "Ann" {
+		t.Errorf("A.Name = %q after rollback, want %q", a.Name, "Ann")
+	}
+}
+
+// TestAtomicBulkCreateRollsBack fails the last row of an atomic bulk create
+// on a duplicate enrollment number and checks the rows before it are gone
+func TestAtomicBulkCreateRollsBack(t *testing.T) {
+	srv := newTestServer(t, nil)
+	mustCreate(t, srv, map[string]interface{}{"enrollment_number": "taken", "name": "Ann", "age": 10, "class": "5A"})
+
+	resp, body := doJSON(t, srv, http.MethodPost, "/student/v1/students/bulk", []map[string]interface{}{
+		{"name": "Ben", "age": 11, "class": "5A"},
+		{"name": "Cat", "age": 12, "class": "5A"},
+		{"enrollment_number": "taken", "name": "Dan", "age": 13, "class": "5A"},
+	})
+	if resp.StatusCode < 400 {
+		t.Fatalf("bulk create with a duplicate row: status %d: %s", resp.StatusCode, body)
+	}
+	if got := len(store.Active()); got != 1 {
+		t.Errorf("%d active students after a failed atomic bulk create, want 1", got)
+	}
+}
+
+// globalLockStore is the store as it was before sharding, one map behind one
+// lock, kept as the baseline for BenchmarkStoreParallel
+type globalLockStore struct {
+	mu       sync.RWMutex
+	students map[string]Student
+}
+
+func (s *globalLockStore) Get(id string) (Student, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	student, exists := s.students[id]
+	return student, exists
+}
+
+func (s *globalLockStore) Put(student Student) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.students[student.EnrollmentNumber] = student
+}
+
+// BenchmarkStoreParallel compares the sharded store with the single-lock
+// baseline under parallel single-key traffic, one write in four. Run it with
+// -cpu 1,4,8 to see the shards pay off as cores are added.
+func BenchmarkStoreParallel(b *testing.B) {
+	// Measure locking alone, not the history the sharded store also keeps
+	saved := cfg
+	cfg.HistoryLimit = 0
+	b.Cleanup(func() { cfg = saved })
+
+	const records = 10000
+	ids := make([]string, records)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("E%05d", i)
+	}
+
+	sharded := newStudentStore()
+	baseline := &globalLockStore{students: make(map[string]Student, records)}
+	for _, id := range ids {
+		student := Student{EnrollmentNumber: id, Name: "Ann", Age: 10, Class: "5A", Subjects: []string{}}
+		sharded.Exclusive(func(tx storeTx) error { tx.Put(student); return nil })
+		baseline.Put(student)
+	}
+
+	run := func(b *testing.B, get func(string), put func(string)) {
+		b.ReportAllocs()
+		var next atomic.Uint64
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				n := next.Add(1)
+				id := ids[n%records]
+				if n%4 == 0 {
+					put(id)
+				} else {
+					get(id)
+				}
+			}
+		})
+	}
+	b.Run("sharded", func(b *testing.B) {
+		run(b, func(id string) { sharded.Get(id) }, func(id string) {
+			sharded.Update(id, func(tx storeTx) error {
+				student, _ := tx.Get(id)
+				student.Age++
+				tx.Put(student)
+				return nil
+			})
+		})
+	})
+	b.Run("global lock", func(b *testing.B) {
+		run(b, func(id string) { baseline.Get(id) }, func(id string) {
+			student, _ := baseline.Get(id)
+			student.Age++
+			baseline.Put(student)
+		})
+	})
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+var errDuplicateSubject = errors.New("student already takes subject")
+var errSubjectNotFound = errors.New("student does not take subject")
+var errSubjectAndSubjects = errors.New("subject and subjects are both given; subject is the old single-subject field, send subjects only")
+
+// hasSubject reports whether subjects contains subject, ignoring case
+func hasSubject(subjects []string, subject string) bool {
+	return slices.IndexFunc(subjects, func(s string) bool {
+		return strings.EqualFold(s, subject)
+	}) >= 0
+}
+
+// withSubjects returns student with a non-nil subject list, so a payload's
+// "subjects": null, [] and an omitted field are all stored, and serialized,
+// as []
+func withSubjects(student Student) Student {
+	if student.Subjects == nil {
+		student.Subjects = []string{}
+	}
+	return student
+}
+
+// UnmarshalJSON accepts the old single-subject field, "subject", as an alias
+// for a one-item subjects list, so clients and backups from before subjects
+// was a list still decode. Sending both is an error.
+func (s *Student) UnmarshalJSON(data []byte) error {
+	decoded := struct {
+		studentJSON
+		Subject *string `json:"subject"`
+	}{studentJSON: studentJSON(*s)}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	if decoded.Subject != nil {
+		var fields map[string]json.RawMessage
+		json.Unmarshal(data, &fields)
+		if _, both := fields["subjects"]; both {
+			return errSubjectAndSubjects
+		}
+		decoded.Subjects = []string{}
+		if *decoded.Subject != "" {
+			decoded.Subjects = []string{*decoded.Subject}
+		}
+	}
+	*s = Student(decoded.studentJSON)
+	return nil
+}
+
+// sameSubjects reports whether two subject lists hold the same subjects,
+// in any order
+func sameSubjects(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = slices.Clone(a), slices.Clone(b)
+	sort.Strings(a)
+	sort.Strings(b)
+	return slices.Equal(a, b)
+}
+
+// GET /student/v1/students/{studentId}/subjects - List a student's subjects
+func getStudentSubjects(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["studentId"]
+
+	student, exists := store.Get(id)
+	if !exists || student.IsDeleted {
+		writeError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	}
+
+	InfoLogger.Printf("Retrieved subjects of student %s", id)
+	writeSubjects(w, http.StatusOK, student.Subjects)
+}
+
+// POST /student/v1/students/{studentId}/subjects - Add one subject from a
+// {"subject": "..."} body, answering 409 if the student already takes it
+func addStudentSubject(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["studentId"]
+
+	var req struct {
+		Subject string `json:"subject"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ErrorLogger.Printf("Failed to decode request body: %v", err)
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
+		return
+	}
+	if errs := checkSubject(nil, "subject", req.Subject); len(errs) > 0 {
+		writeValidationError(w, r, &validationError{Fields: errs})
+		return
+	}
+
+	student, err := updateSubjects(id, func(subjects []string) ([]string, error) {
+		if hasSubject(subjects, req.Subject) {
+			return nil, errDuplicateSubject
+		}
+		return append(slices.Clip(subjects), req.Subject), nil
+	})
+	var verr *validationError
+	switch {
+	case errors.Is(err, errStudentNotFound):
+		writeError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	case errors.Is(err, errDuplicateSubject):
+		writeError(w, http.StatusConflict, codeDuplicateSubject, "Student already takes "+req.Subject)
+		return
+	case errors.As(err, &verr):
+		writeValidationError(w, r, verr)
+		return
+	}
+
+	InfoLogger.Printf("Added subject %s to student %s", req.Subject, id)
+	notifyWebhook(eventStudentUpdated, student)
+	writeSubjects(w, http.StatusCreated, student.Subjects)
+}
+
+// DELETE /student/v1/students/{studentId}/subjects?subject=... - Remove one
+// subject, matched case-insensitively
+func removeStudentSubject(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["studentId"]
+
+	subject, err := singleValue(r.URL.Query(), "subject")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	if subject == "" {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, "Missing subject parameter")
+		return
+	}
+
+	student, err := updateSubjects(id, func(subjects []string) ([]string, error) {
+		if !hasSubject(subjects, subject) {
+			return nil, errSubjectNotFound
+		}
+		return slices.DeleteFunc(slices.Clone(subjects), func(s string) bool {
+			return strings.EqualFold(s, subject)
+		}), nil
+	})
+	var verr *validationError
+	switch {
+	case errors.Is(err, errStudentNotFound):
+		writeError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	case errors.Is(err, errSubjectNotFound):
+		writeError(w, http.StatusNotFound, codeSubjectNotFound, "Student does not take "+subject)
+		return
+	case errors.As(err, &verr):
+		writeValidationError(w, r, verr)
+		return
+	}
+
+	InfoLogger.Printf("Removed subject %s from student %s", subject, id)
+	notifyWebhook(eventStudentUpdated, student)
+	writeSubjects(w, http.StatusOK, student.Subjects)
+}
+
+// updateSubjects replaces an active student's subject list with what change
+// returns. change must not modify the slice it is given, which is shared
+// with copies handed out earlier.
+func updateSubjects(id string, change func(subjects []string) ([]string, error)) (Student, error) {
+	var student Student
+	err := store.Update(id, func(tx storeTx) error {
+		var exists bool
+		student, exists = tx.Get(id)
+		if !exists || student.IsDeleted {
+			return errStudentNotFound
+		}
+
+		subjects, err := change(student.Subjects)
+		if err != nil {
+			return err
+		}
+		student.Subjects = subjects
+		if errs := checkRules(nil, student); len(errs) > 0 {
+			return &validationError{Fields: errs}
+		}
+		student.UpdatedAt = timestamp()
+		tx.Put(student)
+		return nil
+	})
+	return student, err
+}
+
+func writeSubjects(w http.ResponseWriter, status int, subjects []string) {
+	if subjects == nil {
+		subjects = []string{}
+	}
+	writeJSON(w, status, subjects)
+}
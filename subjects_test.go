@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// TestEmptySubjectForms checks null, [] and an omitted subjects field all
+// come back as "subjects": [] after a create and after an update
+func TestEmptySubjectForms(t *testing.T) {
+	forms := []struct {
+		name     string
+		subjects string
+	}{
+		{"null", `,"subjects":null`},
+		{"empty", `,"subjects":[]`},
+		{"omitted", ``},
+	}
+	for _, form := range forms {
+		t.Run(form.name, func(t *testing.T) {
+			srv := newTestServer(t, nil)
+			payload := `{"name":"Ann","age":10,"class":"5A"` + form.subjects + `}`
+
+			id := mustCreate(t, srv, payload)
+			assertEmptySubjects(t, srv, id, "create")
+
+			// Give the student a subject first, so the update has to clear it
+			resp, body := doJSON(t, srv, http.MethodPut, "/student/v1/students/"+id, `{"name":"Ann","age":10,"class":"5A","subjects":["Math"]}`)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("PUT with a subject: status %d: %s", resp.StatusCode, body)
+			}
+			resp, body = doJSON(t, srv, http.MethodPut, "/student/v1/students/"+id, payload)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("PUT: status %d: %s", resp.StatusCode, body)
+			}
+			assertEmptySubjects(t, srv, id, "update")
+
+			stored, _ := store.Get(id)
+			if stored.Subjects == nil {
+				t.Error("stored subjects are nil, want an empty non-nil slice")
+			}
+		})
+	}
+}
+
+func assertEmptySubjects(t *testing.T, srv *httptest.Server, id, after string) {
+	t.Helper()
+	resp, body := doJSON(t, srv, http.MethodGet, "/student/v1/students/"+id, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET after %s: status %d: %s", after, resp.StatusCode, body)
+	}
+	if !bytes.Contains(body, []byte(`"subjects":[]`)) {
+		t.Errorf("GET after %s = %s, want \"subjects\":[]", after, body)
+	}
+}
+
+// TestSubjectAlias sends the old single-subject field on create, update,
+// merge patch and restore
+func TestSubjectAlias(t *testing.T) {
+	srv := newTestServer(t, withAdmin)
+	assertSubjects := func(id, after string, want ...string) {
+		t.Helper()
+		student, _ := store.Get(id)
+		if !slices.Equal(student.Subjects, want) {
+			t.Errorf("subjects after %s = %q, want %q", after, student.Subjects, want)
+		}
+	}
+
+	id := mustCreate(t, srv, `{"name":"Ann","age":10,"class":"5A","subject":"Math"}`)
+	assertSubjects(id, "create", "Math")
+
+	resp, body := doJSON(t, srv, http.MethodPut, "/student/v1/students/"+id, `{"name":"Ann","age":10,"class":"5A","subject":"Art"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT: status %d: %s", resp.StatusCode, body)
+	}
+	assertSubjects(id, "update", "Art")
+
+	resp, body = doJSON(t, srv, http.MethodPatch, "/student/v1/students/"+id, `{"subject":"Music"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PATCH: status %d: %s", resp.StatusCode, body)
+	}
+	assertSubjects(id, "merge patch", "Music")
+
+	for _, tc := range []struct{ method, path, body string }{
+		{http.MethodPost, "/student/v1/students", `{"name":"Ben","age":10,"class":"5A","subject":"Math","subjects":["Art"]}`},
+		{http.MethodPatch, "/student/v1/students/" + id, `{"subject":"Math","subjects":["Art"]}`},
+	} {
+		resp, body = doJSON(t, srv, tc.method, tc.path, tc.body)
+		if resp.StatusCode != http.StatusBadRequest || !bytes.Contains(body, []byte("send subjects only")) {
+			t.Errorf("%s %s with subject and subjects: status %d: %s, want 400 naming both", tc.method, tc.path, resp.StatusCode, body)
+		}
+	}
+
+	// A backup taken before subjects was a list
+	dump := `{"students":[{"enrollment_number":"OLD1","name":"Cat","age":10,"class":"5A","subject":"Art","deleted":true}]}`
+	resp, body = doJSON(t, srv, http.MethodPost, "/admin/restore", dump, adminHeader...)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("restore: status %d: %s", resp.StatusCode, body)
+	}
+	assertSubjects("OLD1", "restore", "Art")
+	if student, _ := store.Get("OLD1"); !student.IsDeleted {
+		t.Error("restored OLD1 is active, want the deleted flag kept")
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Fields that may make up the natural key used by the sync endpoint
+var syncKeyFields = map[string]func(Student) string{
+	"name":  func(s Student) string { return s.Name },
+	"class": func(s Student) string { return s.Class },
+	"subjects": func(s Student) string {
+		subjects := slices.Clone(s.Subjects)
+		sort.Strings(subjects)
+		return strings.Join(subjects, ",")
+	},
+	"age": func(s Student) string { return strconv.Itoa(s.currentAge()) },
+}
+
+// parseSyncKey validates a comma-separated SYNC_KEY such as "name,class"
+func parseSyncKey(raw string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if _, ok := syncKeyFields[field]; !ok {
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// naturalKey joins the configured key fields of a student
+func naturalKey(student Student) string {
+	parts := make([]string, len(cfg.SyncKey))
+	for i, field := range cfg.SyncKey {
+		parts[i] = syncKeyFields[field](student)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// syncResult counts what a sync did with the submitted rows
+type syncResult struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+}
+
+// sameDetails reports whether two records agree on every editable field
+func sameDetails(a, b Student) bool {
+	return a.Name == b.Name && a.Age == b.Age && a.DateOfBirth == b.DateOfBirth && a.Class == b.Class && sameSubjects(a.Subjects, b.Subjects)
+}
+
+// POST /student/v1/students/sync - Upsert rows by natural key
+//
+// Each row matching an active student on SYNC_KEY (default name,class)
+// updates it; other rows create new students. The sync is all-or-nothing:
+// any invalid row (422) or conflict (409) leaves the store untouched.
+func syncStudents(w http.ResponseWriter, r *http.Request) {
+	var rows []Student
+	err := json.NewDecoder(r.Body).Decode(&rows)
+	if err != nil {
+		ErrorLogger.Printf("Failed to decode request body: %v", err)
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
+		return
+	}
+
+	for i := range rows {
+		rows[i] = withoutDeleteAudit(inferClass(rows[i]))
+		if verr := validateNewStudent(rows[i]); verr != nil {
+			for j := range verr.Fields {
+				verr.Fields[j].Field = fmt.Sprintf("[%d].%s", i, verr.Fields[j].Field)
+			}
+			writeValidationError(w, r, verr)
+			return
+		}
+	}
+
+	var result syncResult
+	var created, updated []Student
+	var conflict string
+	err = store.Exclusive(func(tx storeTx) error {
+		existing := make(map[string]Student)
+		tx.Range(func(student Student) bool {
+			if !student.IsDeleted {
+				existing[naturalKey(student)] = student
+			}
+			return true
+		})
+
+		// Plan every change before writing any, so a conflict aborts cleanly
+		staged := make(map[string]Student)
+		var order []string
+		for _, row := range rows {
+			key := naturalKey(row)
+			current, matched := staged[key]
+			if !matched {
+				current, matched = existing[key]
+			}
+
+			if matched {
+				if sameDetails(current, row) {
+					result.Unchanged++
+					continue
+				}
+				current.Name, current.Age, current.DateOfBirth, current.Class, current.Subjects = row.Name, row.Age, row.DateOfBirth, row.Class, row.Subjects
+				current.UpdatedAt = timestamp()
+				result.Updated++
+			} else {
+				current = row
+				if current.EnrollmentNumber == "" {
+					var err error
+					current.EnrollmentNumber, err = allocateEnrollmentNumber(func(id string) bool {
+						if _, exists := tx.Get(id); exists {
+							return true
+						}
+						for _, other := range staged {
+							if storeKey(other.EnrollmentNumber) == storeKey(id) {
+								return true
+							}
+						}
+						return false
+					})
+					if err != nil {
+						return err
+					}
+				}
+				if err := checkUnique(tx, current, ""); err != nil {
+					conflict = current.EnrollmentNumber
+					return err
+				}
+				for _, other := range staged {
+					if storeKey(other.EnrollmentNumber) == storeKey(current.EnrollmentNumber) {
+						conflict = current.EnrollmentNumber
+						return errDuplicateStudent
+					}
+				}
+				current.CreatedAt = timestamp()
+				current.UpdatedAt = current.CreatedAt
+				result.Created++
+			}
+
+			if _, seen := staged[key]; !seen {
+				order = append(order, key)
+			}
+			staged[key] = current
+		}
+
+		// Only classes gaining members are held to their capacity, once every
+		// row is written so moves out of a class make room for moves in
+		var joined []string
+		for _, key := range order {
+			student := staged[key]
+			if previous, wasExisting := existing[key]; wasExisting {
+				updated = append(updated, student)
+				if !strings.EqualFold(previous.Class, student.Class) {
+					joined = append(joined, student.Class)
+				}
+			} else {
+				created = append(created, student)
+				joined = append(joined, student.Class)
+			}
+			tx.Put(student)
+		}
+		// Check uniqueness once every row is written, so updated rows are
+		// compared with the other records as they end up, including rows of
+		// this same batch
+		for _, key := range order {
+			student := staged[key]
+			if err := checkUnique(tx, student, student.EnrollmentNumber); err != nil {
+				conflict = student.EnrollmentNumber
+				return err
+			}
+		}
+		for _, class := range joined {
+			if err := checkClassSize(tx, class); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	var full *classFullError
+	if errors.Is(err, errDuplicateStudent) {
+		writeError(w, http.StatusConflict, codeDuplicateStudent, "Student already exists: "+conflict)
+		return
+	}
+	if errors.As(err, &full) {
+		writeClassFull(w, full)
+		return
+	}
+	if err != nil {
+		ErrorLogger.Printf("Failed to sync students: %v", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Could not allocate an enrollment number")
+		return
+	}
+
+	for _, student := range created {
+		notifyWebhook(eventStudentCreated, student)
+	}
+	for _, student := range updated {
+		notifyWebhook(eventStudentUpdated, student)
+	}
+
+	InfoLogger.Printf("Synced students: %d created, %d updated, %d unchanged", result.Created, result.Updated, result.Unchanged)
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// TestSyncNameClassUniqueness checks a sync can't produce two active students
+// with the same name and class under UNIQUE_BY=name_class, whether an update
+// moves a student onto another or two rows of the batch collide
+func TestSyncNameClassUniqueness(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		existing []map[string]interface{}
+		rows     string
+	}{
+		{
+			"update onto existing",
+			[]map[string]interface{}{{"name": "Ann", "age": 10, "class": "5A"}, {"name": "Ann", "age": 11, "class": "5B"}},
+			`[{"name":"Ann","age":11,"class":"5A"}]`,
+		},
+		{
+			"rows in one batch",
+			nil,
+			`[{"name":"Cat","age":10,"class":"5A"},{"name":"Cat","age":11,"class":"5A"}]`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newTestServer(t, func(c *Config) {
+				c.UniqueBy = uniqueByNameClass
+				c.SyncKey = []string{"name", "age"}
+			})
+			for _, student := range tc.existing {
+				mustCreate(t, srv, student)
+			}
+
+			resp, body := doJSON(t, srv, http.MethodPost, "/student/v1/students/sync", tc.rows)
+			if resp.StatusCode != http.StatusConflict {
+				t.Errorf("sync: status %d: %s, want 409", resp.StatusCode, body)
+			}
+			if got := len(store.Active()); got != len(tc.existing) {
+				t.Errorf("%d students after a refused sync, want %d", got, len(tc.existing))
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/gorilla/mux"
+)
+
+var errTagNotFound = errors.New("student has no such tag")
+
+// Tag keys are kept to characters that need no escaping in a URL path and
+// contain no colon, so ?tag=key:value splits unambiguously
+var tagKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// checkTags appends field errors for more than MAX_TAGS tags, keys that are
+// malformed or too long and values that are too long. Keys are checked in
+// sorted order so the errors come out the same on every call.
+func checkTags(errs []fieldError, tags map[string]string) []fieldError {
+	if len(tags) > cfg.MaxTags {
+		errs = append(errs, newFieldError("tags", fieldTooMany, cfg.MaxTags, len(tags)))
+	}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		field := "tags." + key
+		if !tagKeyPattern.MatchString(key) {
+			errs = append(errs, newFieldError(field, fieldPatternMismatch, tagKeyPattern.String()))
+		}
+		errs = checkLength(errs, field, key, cfg.MaxTagKeyLength)
+		errs = checkLength(errs, field, tags[key], cfg.MaxTagValueLength)
+	}
+	return errs
+}
+
+// GET /student/v1/students/{studentId}/tags - List a student's tags
+func getStudentTags(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["studentId"]
+
+	student, exists := store.Get(id)
+	if !exists || student.IsDeleted {
+		writeError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	}
+
+	InfoLogger.Printf("Retrieved tags of student %s", id)
+	writeTags(w, http.StatusOK, student.Tags)
+}
+
+// GET /student/v1/students/{studentId}/tags/{key} - Read one tag as
+// {"key": ..., "value": ...}
+func getStudentTag(w http.ResponseWriter, r *http.Request) {
+	id, key := mux.Vars(r)["studentId"], mux.Vars(r)["key"]
+
+	student, exists := store.Get(id)
+	if !exists || student.IsDeleted {
+		writeError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	}
+	value, ok := student.Tags[key]
+	if !ok {
+		writeError(w, http.StatusNotFound, codeTagNotFound, "Student has no tag "+key)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"key": key, "value": value})
+}
+
+// PUT /student/v1/students/{studentId}/tags/{key} - Set one tag from a
+// {"value": "..."} body, answering 201 when the tag is new and 200 when it
+// replaced an earlier value. Replies with all of the student's tags.
+func setStudentTag(w http.ResponseWriter, r *http.Request) {
+	id, key := mux.Vars(r)["studentId"], mux.Vars(r)["key"]
+
+	var req struct {
+		Value string `json:"value"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ErrorLogger.Printf("Failed to decode request body: %v", err)
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
+		return
+	}
+
+	created := false
+	student, err := updateTags(id, func(tags map[string]string) (map[string]string, error) {
+		_, replaced := tags[key]
+		created = !replaced
+		tags = maps.Clone(tags)
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		tags[key] = req.Value
+		if errs := checkTags(nil, tags); len(errs) > 0 {
+			return nil, &validationError{Fields: errs}
+		}
+		return tags, nil
+	})
+	var verr *validationError
+	switch {
+	case errors.Is(err, errStudentNotFound):
+		writeError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	case errors.As(err, &verr):
+		writeValidationError(w, r, verr)
+		return
+	}
+
+	InfoLogger.Printf("Set tag %s of student %s", key, id)
+	notifyWebhook(eventStudentUpdated, student)
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeTags(w, status, student.Tags)
+}
+
+// DELETE /student/v1/students/{studentId}/tags/{key} - Remove one tag,
+// replying with the tags left
+func deleteStudentTag(w http.ResponseWriter, r *http.Request) {
+	id, key := mux.Vars(r)["studentId"], mux.Vars(r)["key"]
+
+	student, err := updateTags(id, func(tags map[string]string) (map[string]string, error) {
+		if _, ok := tags[key]; !ok {
+			return nil, errTagNotFound
+		}
+		tags = maps.Clone(tags)
+		delete(tags, key)
+		return tags, nil
+	})
+	var verr *validationError
+	switch {
+	case errors.Is(err, errStudentNotFound):
+		writeError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	case errors.Is(err, errTagNotFound):
+		writeError(w, http.StatusNotFound, codeTagNotFound, "Student has no tag "+key)
+		return
+	case errors.As(err, &verr):
+		writeValidationError(w, r, verr)
+		return
+	}
+
+	InfoLogger.Printf("Removed tag %s from student %s", key, id)
+	notifyWebhook(eventStudentUpdated, student)
+	writeTags(w, http.StatusOK, student.Tags)
+}
+
+// updateTags replaces an active student's tags with what change returns.
+// change must not modify the map it is given, which is shared with copies
+// handed out earlier.
+func updateTags(id string, change func(tags map[string]string) (map[string]string, error)) (Student, error) {
+	var student Student
+	err := store.Update(id, func(tx storeTx) error {
+		var exists bool
+		student, exists = tx.Get(id)
+		if !exists || student.IsDeleted {
+			return errStudentNotFound
+		}
+
+		tags, err := change(student.Tags)
+		if err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			tags = nil
+		}
+		student.Tags = tags
+		if errs := checkRules(nil, student); len(errs) > 0 {
+			return &validationError{Fields: errs}
+		}
+		student.UpdatedAt = timestamp()
+		tx.Put(student)
+		return nil
+	})
+	return student, err
+}
+
+// writeTags replies with tags as an object keyed by tag, which JSON_NAMING
+// must leave alone
+func writeTags(w http.ResponseWriter, status int, tags map[string]string) {
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	markDataKeyed(w)
+	writeJSON(w, status, tags)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Endpoints that stream for as long as their upload lasts, which no fixed
+// deadline fits. timeoutMiddleware leaves them without one; they still stop
+// when the client disconnects.
+var streamingPaths = map[string]bool{
+	"/student/v1/students/import": true,
+}
+
+// timeoutMiddleware gives every request outside streamingPaths a context
+// deadline: the client's X-Request-Timeout-Ms, clamped to
+// MAX_REQUEST_TIMEOUT, or REQUEST_TIMEOUT when the header is absent. Scans
+// stop once the deadline passes (see scanStudents), and a handler that gave
+// up without replying is answered with 504 here.
+func timeoutMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if streamingPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		timeout := cfg.RequestTimeout
+		if raw := r.Header.Get("X-Request-Timeout-Ms"); raw != "" {
+			ms, err := strconv.Atoi(raw)
+			if err != nil || ms <= 0 {
+				writeError(w, http.StatusBadRequest, codeInvalidParameter, "Invalid X-Request-Timeout-Ms: must be a positive integer")
+				return
+			}
+			timeout = time.Duration(ms) * time.Millisecond
+		}
+		if cfg.MaxRequestTimeout > 0 && (timeout == 0 || timeout > cfg.MaxRequestTimeout) {
+			timeout = cfg.MaxRequestTimeout
+		}
+		if timeout == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		r = r.WithContext(ctx)
+		rec := &writeTracker{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if !rec.wrote && errors.Is(requestErr(r), context.DeadlineExceeded) {
+			ErrorLogger.Printf("Request %s %s exceeded its %v deadline", r.Method, r.URL.Path, timeout)
+			writeError(w, http.StatusGatewayTimeout, codeRequestTimeout, "Request timed out")
+		}
+	})
+}
+
+// writeTracker notes whether a handler has started its response
+type writeTracker struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (t *writeTracker) WriteHeader(status int) {
+	t.wrote = true
+	t.ResponseWriter.WriteHeader(status)
+}
+
+func (t *writeTracker) Write(p []byte) (int, error) {
+	t.wrote = true
+	return t.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (t *writeTracker) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
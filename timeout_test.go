@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestTimeoutAnswers504 runs a handler that gives up at its deadline without
+// replying, under both JSON_NAMING styles
+func TestTimeoutAnswers504(t *testing.T) {
+	for _, naming := range []string{namingSnake, namingCamel} {
+		t.Run(naming, func(t *testing.T) {
+			newTestHandler(t, func(c *Config) {
+				c.JSONNaming = naming
+				c.RequestTimeout = 20 * time.Millisecond
+			})
+			handler := chain(middlewareStack()...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/student/v1/students", nil))
+			if rec.Code != http.StatusGatewayTimeout {
+				t.Errorf("status %d: %q, want 504", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+)
+
+// GET /student/v1/trash - List soft-deleted students, most recently deleted
+// first. Supports limit, offset and X-Page-Size like the main list.
+func getTrash(w http.ResponseWriter, r *http.Request) {
+	var query listQuery
+	var err error
+	if query.Limit, err = parseNonNegative(r.URL.Query(), "limit"); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	if query.Offset, err = parseNonNegative(r.URL.Query(), "offset"); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	w.Header().Add("Vary", pageSizeHeader)
+	if err = applyPageSize(r, &query); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	var result []Student
+	ok := scanStudents(r, func(student Student) {
+		if student.IsDeleted {
+			result = append(result, student)
+		}
+	})
+	if !ok {
+		return
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i].DeletedAt, result[j].DeletedAt
+		if a != nil && b != nil && !a.Equal(*b) {
+			return a.After(*b)
+		}
+		return result[i].EnrollmentNumber < result[j].EnrollmentNumber
+	})
+	result = query.paginate(result)
+
+	if cfg.MaxListResults > 0 && len(result) > cfg.MaxListResults {
+		result = result[:cfg.MaxListResults]
+		w.Header().Set("X-Result-Truncated", "true")
+	}
+
+	InfoLogger.Printf("Retrieved trash")
+	writeJSONWithETag(w, r, listResponse(r, result))
+}
+
+// withoutDeleteAudit clears the soft-delete details a client sent along with
+// a student. Only the delete handlers record who deleted a student, when and
+// why, so creates and updates never take them from the body.
+func withoutDeleteAudit(student Student) Student {
+	student.DeletedAt = nil
+	student.DeletedBy = ""
+	student.DeleteReason = ""
+	return student
+}
+
+// POST /student/v1/students/{studentId}/restore - Undo a soft delete
+func restoreStudent(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["studentId"]
+
+	var student Student
+	err := writeUnique(id, func(tx storeTx) error {
+		var exists bool
+		student, exists = tx.Get(id)
+		if !exists || !student.IsDeleted {
+			return errStudentNotFound
+		}
+
+		student.IsDeleted = false
+		student.DeletedAt = nil
+		student.DeletedBy = ""
+		student.DeleteReason = ""
+		if err := checkUnique(tx, student, id); err != nil {
+			return err
+		}
+		if err := checkCapacity(tx, student, id); err != nil {
+			return err
+		}
+		student.UpdatedAt = timestamp()
+		tx.Put(student)
+		return nil
+	})
+	var full *classFullError
+	if errors.Is(err, errStudentNotFound) {
+		writeError(w, http.StatusNotFound, codeStudentNotFound, "Deleted student not found")
+		return
+	}
+	if errors.As(err, &full) {
+		writeClassFull(w, full)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusConflict, codeDuplicateStudent, "Student already exists")
+		return
+	}
+
+	InfoLogger.Printf("Restored student: %v", student)
+	notifyWebhook(eventStudentUpdated, student)
+	writeJSON(w, http.StatusOK, student)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// TestDeleteAuditIsServerOwned sends soft-delete details on every route that
+// takes a student and checks none of them reach the store
+func TestDeleteAuditIsServerOwned(t *testing.T) {
+	srv := newTestServer(t, nil)
+	audit := `"deleted_at":"2020-01-01T00:00:00Z","deleted_by":"mallory","delete_reason":"forged"`
+
+	assertClean := func(id, after string) {
+		t.Helper()
+		student, exists := store.Get(id)
+		if !exists {
+			t.Fatalf("%s: %s not stored", after, id)
+		}
+		if student.DeletedAt != nil || student.DeletedBy != "" || student.DeleteReason != "" {
+			t.Errorf("%s: stored %s with deleted_at %v, deleted_by %q and delete_reason %q, want none", after, id, student.DeletedAt, student.DeletedBy, student.DeleteReason)
+		}
+	}
+
+	id := mustCreate(t, srv, `{"name":"Ann","age":10,"class":"5A",`+audit+`}`)
+	assertClean(id, "create")
+
+	resp, body := doJSON(t, srv, http.MethodPut, "/student/v1/students/"+id, `{"name":"Ann","age":11,"class":"5A",`+audit+`}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT: status %d: %s", resp.StatusCode, body)
+	}
+	assertClean(id, "update")
+
+	for _, route := range []string{"bulk", "import"} {
+		resp, body = doJSON(t, srv, http.MethodPost, "/student/v1/students/"+route, `[{"enrollment_number":"`+route+`-1","name":"Ben","age":10,"class":"5A",`+audit+`}]`)
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+			t.Fatalf("%s: status %d: %s", route, resp.StatusCode, body)
+		}
+		assertClean(route+"-1", route)
+	}
+
+	resp, body = doJSON(t, srv, http.MethodPost, "/student/v1/students/sync", `[{"name":"Cat","age":10,"class":"5A",`+audit+`}]`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("sync: status %d: %s", resp.StatusCode, body)
+	}
+	resp, body = doJSON(t, srv, http.MethodPost, "/student/v1/students/assign-ids?commit=true", `[{"name":"Dan","age":10,"class":"5A",`+audit+`}]`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("assign-ids: status %d: %s", resp.StatusCode, body)
+	}
+	for _, student := range store.Active() {
+		assertClean(student.EnrollmentNumber, "sync and assign-ids")
+	}
+
+	for _, field := range []string{"deleted_at", "deleted_by", "delete_reason"} {
+		resp, body = doJSON(t, srv, http.MethodPatch, "/student/v1/students/"+id, `{"`+field+`":"2020-01-01T00:00:00Z"}`)
+		if resp.StatusCode < 400 {
+			t.Errorf("PATCH %s: status %d, want it rejected as read-only: %s", field, resp.StatusCode, body)
+		}
+	}
+	assertClean(id, "patch")
+
+	// The delete handler still records the actor
+	resp, body = doJSON(t, srv, http.MethodDelete, "/student/v1/students/"+id, nil, "X-Actor", "admin")
+	if resp.StatusCode >= 300 {
+		t.Fatalf("delete: status %d: %s", resp.StatusCode, body)
+	}
+	if student, _ := store.Get(id); student.DeletedBy != "admin" || student.DeletedAt == nil {
+		t.Errorf("after delete: deleted_by %q deleted_at %v, want admin and a time", student.DeletedBy, student.DeletedAt)
+	}
+}
+
+// TestApplyPatchKeepsDeleteAudit patches a document that lost its audit
+// fields and checks the stored ones survive
+func TestApplyPatchKeepsDeleteAudit(t *testing.T) {
+	deletedAt := timestamp()
+	stored := Student{EnrollmentNumber: "A", Name: "Ann", Age: 10, Class: "5A", IsDeleted: true, DeletedAt: &deletedAt, DeletedBy: "admin", DeleteReason: "left school"}
+	patched, err := applyPatch(stored, func(doc map[string]interface{}) error {
+		doc["name"] = "Anna"
+		delete(doc, "deleted_at")
+		delete(doc, "deleted_by")
+		delete(doc, "delete_reason")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("applyPatch: %v", err)
+	}
+	if patched.Name != "Anna" || patched.DeletedAt == nil || patched.DeletedBy != "admin" || patched.DeleteReason != "left school" {
+		t.Errorf("applyPatch = %+v, want the new name and the stored audit fields", patched)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"embed"
+	"net/http"
+)
+
+// The admin UI is a single static page compiled into the binary
+//
+//go:embed ui/index.html
+var uiFiles embed.FS
+
+// GET /admin/ui - A small HTML page for manual data entry, enabled with
+// ADMIN_UI. Like every admin route it needs the ADMIN_TOKEN bearer token, so
+// browsers reach it through a proxy or extension that adds the header. The
+// page itself works through the public student endpoints with fetch.
+func adminUI(w http.ResponseWriter, r *http.Request) {
+	if !cfg.AdminUI {
+		routeNotFound(w, r)
+		return
+	}
+	http.ServeFileFS(w, r, uiFiles, "ui/index.html")
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// Uniqueness policies selectable via UNIQUE_BY.
+//
+// Enrollment numbers are the store key, so an active record can never be
+// overwritten by a create carrying a client-supplied enrollment number under
+// any policy. With ENROLLMENT_CASE_INSENSITIVE the comparison ignores case,
+// see storeKey. The policies differ in what else they reserve:
+//   - enrollment: enrollment numbers stay reserved even after a soft delete
+//   - name_class: no two active students may share the same name and class
+//   - none: only active records block reuse of an enrollment number
+const (
+	uniqueByEnrollment = "enrollment"
+	uniqueByNameClass  = "name_class"
+	uniqueByNone       = "none"
+)
+
+var errDuplicateStudent = errors.New("student violates uniqueness policy")
+
+// checkUnique reports whether student may be stored under the active policy.
+// excludeID is the record being updated, or empty on create. The transaction
+// must come from writeUnique so it covers every record the policy compares.
+func checkUnique(tx storeTx, student Student, excludeID string) error {
+	if excludeID == "" {
+		if existing, exists := tx.Get(student.EnrollmentNumber); exists {
+			if !existing.IsDeleted || cfg.UniqueBy != uniqueByNone {
+				return errDuplicateStudent
+			}
+		}
+	}
+
+	if cfg.UniqueBy == uniqueByNameClass {
+		var err error
+		tx.Range(func(existing Student) bool {
+			if storeKey(existing.EnrollmentNumber) == storeKey(excludeID) || existing.IsDeleted {
+				return true
+			}
+			if existing.Name == student.Name && existing.Class == student.Class {
+				err = errDuplicateStudent
+				return false
+			}
+			return true
+		})
+		return err
+	}
+	return nil
+}
+
+// writeUnique runs fn with as much of the store locked as checkUnique and
+// checkCapacity need: just id's shard, or everything when the policy compares
+// across records or classes have capacities to count
+func writeUnique(id string, fn func(tx storeTx) error) error {
+	if cfg.UniqueBy == uniqueByNameClass || len(cfg.ClassCapacity) > 0 {
+		return store.Exclusive(fn)
+	}
+	return store.Update(id, fn)
+}
+
+// GET /student/v1/students/lookup?name=...&class=... - Get the active student
+// with exactly this name and class, the key name_class uniqueness guards.
+// class may be empty or omitted for students without one. Matches made
+// ambiguous by data predating that policy answer 409 with every matching
+// enrollment number, so the caller can pick one.
+func lookupStudent(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	name, err := singleValue(values, "name")
+	var class string
+	if err == nil {
+		class, err = singleValue(values, "class")
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	if name == "" {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, "Missing name parameter")
+		return
+	}
+
+	var matches []Student
+	ok := scanStudents(r, func(student Student) {
+		if !student.IsDeleted && student.Name == name && student.Class == class {
+			matches = append(matches, student)
+		}
+	})
+	if !ok {
+		return
+	}
+
+	switch len(matches) {
+	case 0:
+		writeError(w, http.StatusNotFound, codeStudentNotFound, "Student not found")
+		return
+	case 1:
+	default:
+		ids := make([]string, len(matches))
+		for i, student := range matches {
+			ids[i] = student.EnrollmentNumber
+		}
+		sort.Strings(ids)
+		writeJSON(w, http.StatusConflict, map[string]interface{}{
+			"code":               codeAmbiguousLookup,
+			"error":              fmt.Sprintf("%d students match name and class", len(ids)),
+			"enrollment_numbers": ids,
+		})
+		return
+	}
+
+	student := matches[0]
+	if setLastModified(w, r, student.UpdatedAt) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	InfoLogger.Printf("Looked up student: %v", student)
+	writeJSONWithETag(w, r, student)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Accepted age range for a student
+const (
+	minAge = 1
+	maxAge = 150
+)
+
+// fieldError describes one field that failed business validation. Code is
+// one of the field* constants; Message is its human-readable rendering, see
+// newFieldError.
+type fieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+
+	// args fill in the message template when it is localized
+	args []interface{}
+}
+
+// validationError is well-formed input that breaks a business rule. Handlers
+// answer it with 422, keeping 400 for payloads that aren't valid JSON.
+type validationError struct {
+	Fields []fieldError
+}
+
+func (e *validationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// validateStudent checks the business rules for a student's editable fields,
+// returning nil when the record is acceptable
+func validateStudent(student Student) *validationError {
+	var errs []fieldError
+	if strings.TrimSpace(student.Name) == "" {
+		errs = append(errs, newFieldError("name", fieldRequired))
+	}
+	if student.DateOfBirth != "" {
+		errs = checkDateOfBirth(errs, student.DateOfBirth)
+	} else if student.Age < minAge || student.Age > maxAge {
+		errs = append(errs, newFieldError("age", fieldOutOfRange, minAge, maxAge))
+	}
+	errs = checkLength(errs, "name", student.Name, cfg.MaxNameLength)
+	errs = checkClass(errs, "class", student.Class)
+	for i, subject := range student.Subjects {
+		field := fmt.Sprintf("subjects[%d]", i)
+		errs = checkSubject(errs, field, subject)
+		if hasSubject(student.Subjects[:i], subject) {
+			errs = append(errs, newFieldError(field, fieldDuplicateSubject))
+		}
+	}
+	errs = checkTags(errs, student.Tags)
+	errs = checkRules(errs, student)
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return &validationError{Fields: errs}
+}
+
+// validateNewStudent runs validateStudent plus the checks that only apply to
+// records being created: the client may choose the enrollment number, and
+// with SUBJECT_CLASS_MAP set a class must be given or inferable, see
+// inferClass, which callers apply first
+func validateNewStudent(student Student) *validationError {
+	var errs []fieldError
+	if verr := validateStudent(student); verr != nil {
+		errs = verr.Fields
+	}
+	if student.EnrollmentNumber != "" && cfg.EnrollmentPattern != nil && !cfg.EnrollmentPattern.MatchString(student.EnrollmentNumber) {
+		errs = append(errs, newFieldError("enrollment_number", fieldPatternMismatch, cfg.EnrollmentPattern.String()))
+	}
+	if student.Class == "" && len(cfg.SubjectClassMap) > 0 {
+		errs = append(errs, newFieldError("class", fieldNotInferable))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return &validationError{Fields: errs}
+}
+
+// checkDateOfBirth appends a field error unless dob is a past date giving an
+// age within the accepted range
+func checkDateOfBirth(errs []fieldError, dob string) []fieldError {
+	parsed, err := parseDateOfBirth(dob)
+	switch {
+	case err != nil:
+		return append(errs, newFieldError("date_of_birth", fieldInvalidDate))
+	case !parsed.Before(timestamp()):
+		return append(errs, newFieldError("date_of_birth", fieldNotInPast))
+	}
+	if age := ageOn(parsed, timestamp()); age < minAge || age > maxAge {
+		return append(errs, newFieldError("date_of_birth", fieldAgeOutOfRange, minAge, maxAge))
+	}
+	return errs
+}
+
+// checkClass appends field errors for a class that is too long or, when
+// ALLOWED_CLASSES is set, not in the allowlist. The allowlist is matched
+// case-insensitively, like CLASS_CAPACITY and the class filters. A student
+// without a class is always accepted.
+func checkClass(errs []fieldError, field, class string) []fieldError {
+	errs = checkLength(errs, field, class, cfg.MaxClassLength)
+	if class != "" && len(cfg.AllowedClasses) > 0 && !isAllowedClass(class) {
+		errs = append(errs, newFieldError(field, fieldNotAllowed, strings.Join(cfg.AllowedClasses, ", ")))
+	}
+	return errs
+}
+
+// isAllowedClass reports whether class is in ALLOWED_CLASSES, ignoring case
+func isAllowedClass(class string) bool {
+	return slices.ContainsFunc(cfg.AllowedClasses, func(allowed string) bool {
+		return strings.EqualFold(allowed, class)
+	})
+}
+
+// checkSubject appends field errors for a subject that is blank or too long
+func checkSubject(errs []fieldError, field, subject string) []fieldError {
+	if strings.TrimSpace(subject) == "" {
+		errs = append(errs, newFieldError(field, fieldRequired))
+	}
+	return checkLength(errs, field, subject, cfg.MaxSubjectLength)
+}
+
+// checkLength appends a field error when value has more than max characters
+func checkLength(errs []fieldError, field, value string, max int) []fieldError {
+	if n := utf8.RuneCountInString(value); n > max {
+		errs = append(errs, newFieldError(field, fieldTooLong, max, n))
+	}
+	return errs
+}
+
+// GET /student/v1/schema - Describe the constraints validateStudent enforces,
+// built-in ones by field and VALIDATION_RULES as configured
+func getSchema(w http.ResponseWriter, r *http.Request) {
+	enrollmentNumber := map[string]interface{}{"type": "string", "required": false}
+	if cfg.EnrollmentPattern != nil {
+		enrollmentNumber["pattern"] = cfg.EnrollmentPattern.String()
+	}
+	class := map[string]interface{}{"type": "string", "required": false, "max_length": cfg.MaxClassLength}
+	if len(cfg.AllowedClasses) > 0 {
+		class["enum"] = cfg.AllowedClasses
+	}
+
+	rules := cfg.ValidationRules
+	if rules == nil {
+		rules = []validationRule{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"rules": rules,
+		"fields": map[string]interface{}{
+			"enrollment_number": enrollmentNumber,
+			"name":              map[string]interface{}{"type": "string", "required": true, "max_length": cfg.MaxNameLength},
+			"age":               map[string]interface{}{"type": "integer", "required": true, "required_unless": "date_of_birth", "minimum": minAge, "maximum": maxAge},
+			"date_of_birth":     map[string]interface{}{"type": "string", "format": "date", "required": false},
+			"class":             class,
+			"subjects":          map[string]interface{}{"type": "array", "required": false, "unique_items": true, "items": map[string]interface{}{"type": "string", "max_length": cfg.MaxSubjectLength}},
+			"tags":              map[string]interface{}{"type": "object", "required": false, "max_properties": cfg.MaxTags, "key_pattern": tagKeyPattern.String(), "key_max_length": cfg.MaxTagKeyLength, "value_max_length": cfg.MaxTagValueLength},
+		},
+	})
+}
+
+// writeValidationError replies 422 with the failing fields, their messages
+// in the language the client asked for
+func writeValidationError(w http.ResponseWriter, r *http.Request, verr *validationError) {
+	lang := requestLanguage(r)
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+	writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+		"code":   codeValidationFailed,
+		"error":  message(lang, codeValidationFailed),
+		"fields": localize(verr.Fields, lang),
+	})
+}
+
+// POST /student/v1/students/validate - Check a payload the way create would,
+// including the uniqueness policy, without storing anything. Always answers
+// 200 for well-formed JSON so forms can call it on every keystroke.
+func validateStudentPayload(w http.ResponseWriter, r *http.Request) {
+	var student Student
+	err := json.NewDecoder(r.Body).Decode(&student)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidJSON, decodeErrorMessage(err))
+		return
+	}
+
+	student = inferClass(student)
+	var fields []fieldError
+	if verr := validateNewStudent(student); verr != nil {
+		fields = verr.Fields
+	}
+	if student.EnrollmentNumber != "" || cfg.UniqueBy == uniqueByNameClass {
+		store.View(func(tx storeTx) {
+			if checkUnique(tx, student, "") != nil {
+				fields = append(fields, newFieldError(uniqueField(tx, student), fieldAlreadyExists))
+			}
+		})
+	}
+	if _, limited := classCapacity(student.Class); limited {
+		store.View(func(tx storeTx) {
+			var full *classFullError
+			if errors.As(checkCapacity(tx, student, ""), &full) {
+				fields = append(fields, newFieldError("class", fieldClassFull, full.Class, full.Capacity))
+			}
+		})
+	}
+
+	if len(fields) > 0 {
+		lang := requestLanguage(r)
+		w.Header().Set("Content-Language", lang)
+		w.Header().Add("Vary", "Accept-Language")
+		writeJSON(w, http.StatusOK, map[string]interface{}{"valid": false, "fields": localize(fields, lang)})
+		return
+	}
+
+	// Preview the record as create would store it, minus the enrollment
+	// number, which generating would consume
+	student.CreatedAt = timestamp()
+	student.UpdatedAt = student.CreatedAt
+	writeJSON(w, http.StatusOK, map[string]interface{}{"valid": true, "normalized": student})
+}
+
+// uniqueField names the field a uniqueness conflict should be reported on
+func uniqueField(tx storeTx, student Student) string {
+	if student.EnrollmentNumber != "" {
+		if _, exists := tx.Get(student.EnrollmentNumber); exists {
+			return "enrollment_number"
+		}
+	}
+	return "name"
+}
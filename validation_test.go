@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// TestMalformedVersusInvalid checks write handlers answer 400 for bodies
+// that don't decode and 422, naming the fields, for well-formed bodies that
+// break a rule
+func TestMalformedVersusInvalid(t *testing.T) {
+	srv := newTestServer(t, nil)
+	id := mustCreate(t, srv, map[string]interface{}{"name": "Ann", "age": 10, "class": "5A"})
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+		fields []string
+	}{
+		{"POST malformed", http.MethodPost, "/student/v1/students", `{"name":"Ann",`, http.StatusBadRequest, codeInvalidJSON, nil},
+		{"POST wrong type", http.MethodPost, "/student/v1/students", `{"name":"Ann","age":"ten","class":"5A"}`, http.StatusBadRequest, codeInvalidJSON, nil},
+		{"POST empty", http.MethodPost, "/student/v1/students", ``, http.StatusBadRequest, codeInvalidJSON, nil},
+		{"POST invalid", http.MethodPost, "/student/v1/students", `{"name":"","age":-1,"class":"5A"}`, http.StatusUnprocessableEntity, codeValidationFailed, []string{"name", "age"}},
+		{"PUT malformed", http.MethodPut, "/student/v1/students/" + id, `{"name":`, http.StatusBadRequest, codeInvalidJSON, nil},
+		{"PUT wrong type", http.MethodPut, "/student/v1/students/" + id, `{"name":"Ann","age":10,"class":5}`, http.StatusBadRequest, codeInvalidJSON, nil},
+		{"PUT invalid", http.MethodPut, "/student/v1/students/" + id, `{"name":"Ann","age":500,"class":"5A"}`, http.StatusUnprocessableEntity, codeValidationFailed, []string{"age"}},
+		{"PATCH malformed", http.MethodPatch, "/student/v1/students/" + id, `{"age":`, http.StatusBadRequest, codeInvalidPatch, nil},
+		{"PATCH wrong type", http.MethodPatch, "/student/v1/students/" + id, `{"age":"ten"}`, http.StatusBadRequest, codeInvalidPatch, nil},
+		{"PATCH invalid", http.MethodPatch, "/student/v1/students/" + id, `{"name":""}`, http.StatusUnprocessableEntity, codeValidationFailed, []string{"name"}},
+		{"validate malformed", http.MethodPost, "/student/v1/students/validate", `[`, http.StatusBadRequest, codeInvalidJSON, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, body := doJSON(t, srv, tc.method, tc.path, tc.body)
+			var reply struct {
+				Code   string       `json:"code"`
+				Fields []fieldError `json:"fields"`
+			}
+			json.Unmarshal(body, &reply)
+			if resp.StatusCode != tc.status || reply.Code != tc.code {
+				t.Fatalf("status %d code %q, want %d %q: %s", resp.StatusCode, reply.Code, tc.status, tc.code, body)
+			}
+			got := make(map[string]bool)
+			for _, field := range reply.Fields {
+				got[field.Field] = true
+			}
+			for _, field := range tc.fields {
+				if !got[field] {
+					t.Errorf("field errors %s, want one for %s", body, field)
+				}
+			}
+		})
+	}
+
+	if student, _ := store.Get(id); student.Name != "Ann" || student.Age != 10 {
+		t.Errorf("after rejected writes: %+v, want Ann aged 10 unchanged", student)
+	}
+}
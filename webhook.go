@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Lifecycle event types sent to WEBHOOK_URL
+const (
+	eventStudentCreated = "student.created"
+	eventStudentUpdated = "student.updated"
+	eventStudentDeleted = "student.deleted"
+	// Sent instead of the individual events gathered within
+	// WEBHOOK_COALESCE_WINDOW
+	eventStudentsBulkChanged = "students.bulk_changed"
+)
+
+// Bounds on the delay between delivery attempts, which doubles per retry
+const (
+	webhookBaseBackoff = time.Second
+	webhookMaxBackoff  = 30 * time.Second
+)
+
+// webhookEvent is the JSON body POSTed for each mutation
+type webhookEvent struct {
+	Type      string    `json:"type"`
+	Student   Student   `json:"student"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// bulkChangedEvent is the JSON body POSTed for a batch of coalesced events,
+// listing each change in the order it happened
+type bulkChangedEvent struct {
+	Type      string          `json:"type"`
+	Changes   []webhookChange `json:"changes"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// webhookChange identifies one mutation within a bulkChangedEvent
+type webhookChange struct {
+	Type             string `json:"type"`
+	EnrollmentNumber string `json:"enrollment_number"`
+}
+
+// queuedWebhook is an encoded event waiting for delivery
+type queuedWebhook struct {
+	eventType        string
+	enrollmentNumber string
+	body             []byte
+}
+
+// Events are delivered in order by a single worker, started on first use,
+// from a queue bounded by WEBHOOK_QUEUE_SIZE
+var (
+	webhookQueue     chan queuedWebhook
+	webhookQueueOnce sync.Once
+)
+
+// notifyWebhook queues a lifecycle event for background delivery. It never
+// blocks: when the queue is full the oldest event is dropped to make room.
+// Delivery failures are logged and never affect the request that caused the
+// event.
+func notifyWebhook(eventType string, student Student) {
+	if cfg.WebhookURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(webhookEvent{Type: eventType, Student: student, Timestamp: timestamp()})
+	if err != nil {
+		ErrorLogger.Printf("Failed to encode webhook event %s: %v", eventType, err)
+		return
+	}
+
+	webhookQueueOnce.Do(func() {
+		webhookQueue = make(chan queuedWebhook, cfg.WebhookQueueSize)
+		go runWebhookWorker()
+	})
+	event := queuedWebhook{eventType: eventType, enrollmentNumber: student.EnrollmentNumber, body: body}
+	for {
+		select {
+		case webhookQueue <- event:
+			return
+		default:
+		}
+		select {
+		case dropped := <-webhookQueue:
+			WarnLogger.Printf("Webhook queue full (%d events), dropped oldest %s event", cfg.WebhookQueueSize, dropped.eventType)
+		default:
+		}
+	}
+}
+
+// runWebhookWorker delivers queued events. With WEBHOOK_COALESCE_WINDOW set,
+// an event arriving while no window is open is sent at once and opens one;
+// events arriving within it are held and sent together when it closes, as a
+// single students.bulk_changed event unless only one came. A window that
+// closes with events held opens another, so a long import keeps batching.
+func runWebhookWorker() {
+	breaker := &webhookBreaker{}
+	var held []queuedWebhook
+	var window <-chan time.Time
+	for {
+		select {
+		case event := <-webhookQueue:
+			switch {
+			case cfg.WebhookCoalesceWindow <= 0:
+				deliverWebhook(breaker, event)
+			case window == nil:
+				deliverWebhook(breaker, event)
+				window = time.After(cfg.WebhookCoalesceWindow)
+			default:
+				held = append(held, event)
+			}
+		case <-window:
+			window = nil
+			if len(held) == 0 {
+				continue
+			}
+			if len(held) == 1 {
+				deliverWebhook(breaker, held[0])
+			} else if batch, ok := coalesceWebhooks(held); ok {
+				deliverWebhook(breaker, batch)
+			}
+			held = nil
+			window = time.After(cfg.WebhookCoalesceWindow)
+		}
+	}
+}
+
+// coalesceWebhooks encodes events as one students.bulk_changed event
+func coalesceWebhooks(events []queuedWebhook) (queuedWebhook, bool) {
+	changes := make([]webhookChange, len(events))
+	for i, event := range events {
+		changes[i] = webhookChange{Type: event.eventType, EnrollmentNumber: event.enrollmentNumber}
+	}
+	body, err := json.Marshal(bulkChangedEvent{Type: eventStudentsBulkChanged, Changes: changes, Timestamp: timestamp()})
+	if err != nil {
+		ErrorLogger.Printf("Failed to encode webhook event %s of %d changes: %v", eventStudentsBulkChanged, len(changes), err)
+		return queuedWebhook{}, false
+	}
+	return queuedWebhook{eventType: eventStudentsBulkChanged, body: body}, true
+}
+
+// deliverWebhook POSTs an event, retrying with jittered exponential backoff
+// until a 2xx response or cfg.WebhookRetries further attempts have failed
+func deliverWebhook(breaker *webhookBreaker, event queuedWebhook) {
+	var err error
+	for attempt := 0; attempt <= cfg.WebhookRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(webhookBackoff(attempt))
+		}
+		breaker.wait()
+		err = postWebhook(event.body)
+		if err != nil {
+			ErrorLogger.Printf("Webhook delivery of %s failed (attempt %d): %v", event.eventType, attempt+1, err)
+		}
+		breaker.record(err)
+		if err == nil {
+			return
+		}
+	}
+	ErrorLogger.Printf("Giving up on webhook delivery of %s: %v", event.eventType, err)
+}
+
+// webhookBackoff returns the delay before retry number attempt: doubling
+// from webhookBaseBackoff up to webhookMaxBackoff, with the upper half
+// randomized so many instances retrying at once spread out
+func webhookBackoff(attempt int) time.Duration {
+	d := webhookMaxBackoff
+	if attempt < 16 {
+		d = min(webhookBaseBackoff<<(attempt-1), webhookMaxBackoff)
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// webhookBreaker stops delivery attempts for WEBHOOK_BREAKER_COOLDOWN once
+// WEBHOOK_BREAKER_THRESHOLD attempts in a row have failed, so a receiver
+// that is down isn't hammered. After the cooldown one trial attempt is made:
+// success closes the breaker, failure opens it again. It is only used by the
+// webhook worker, so it needs no locking.
+type webhookBreaker struct {
+	failures  int
+	openUntil time.Time
+	open      bool
+}
+
+// wait blocks until the breaker allows an attempt
+func (b *webhookBreaker) wait() {
+	if !b.open {
+		return
+	}
+	if d := time.Until(b.openUntil); d > 0 {
+		time.Sleep(d)
+	}
+	InfoLogger.Printf("Webhook circuit breaker half-open, trying delivery again")
+}
+
+// record counts the outcome of an attempt, opening or closing the breaker
+func (b *webhookBreaker) record(err error) {
+	if err == nil {
+		if b.open {
+			InfoLogger.Printf("Webhook circuit breaker closed, delivery recovered")
+		}
+		b.failures, b.open = 0, false
+		return
+	}
+
+	b.failures++
+	if cfg.WebhookBreakerThreshold > 0 && (b.open || b.failures >= cfg.WebhookBreakerThreshold) {
+		b.open = true
+		b.openUntil = time.Now().Add(cfg.WebhookBreakerCooldown)
+		WarnLogger.Printf("Webhook circuit breaker open after %d consecutive failures, pausing delivery for %v", b.failures, cfg.WebhookBreakerCooldown)
+	}
+}
+
+func postWebhook(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.WebhookSecret != "" {
+		req.Header.Set("X-Webhook-Signature", "sha256="+signWebhook(body))
+	}
+
+	client := &http.Client{Timeout: cfg.WebhookTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// signWebhook returns the hex HMAC-SHA256 of body keyed by WEBHOOK_SECRET
+func signWebhook(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(cfg.WebhookSecret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
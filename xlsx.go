@@ -0,0 +1,161 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Media type of Office Open XML spreadsheets
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// Header row of the XLSX and CSV exports, one column per studentRow value
+var exportHeader = []string{"Enrollment Number", "Name", "Age", "Date of Birth", "Class", "Subjects", "Created At", "Updated At", "Deleted At"}
+
+// studentRow lays a student out as the export's cells; ints become numbers
+func studentRow(s Student) []interface{} {
+	deletedAt := ""
+	if s.DeletedAt != nil {
+		deletedAt = s.DeletedAt.Format(time.RFC3339)
+	}
+	return []interface{}{
+		s.EnrollmentNumber, s.Name, s.currentAge(), s.DateOfBirth, s.Class, strings.Join(s.Subjects, ", "),
+		s.CreatedAt.Format(time.RFC3339), s.UpdatedAt.Format(time.RFC3339), deletedAt,
+	}
+}
+
+// GET /student/v1/students.xlsx - Download students as an Excel workbook
+//
+// Accepts the list endpoint's filters and pagination, rows ordered by
+// enrollment number. An export is a deliberate download, so MAX_LIST_RESULTS
+// doesn't apply.
+func exportStudentsXLSX(w http.ResponseWriter, r *http.Request) {
+	query, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	var result []Student
+	ok := scanStudents(r, func(student Student) {
+		if query.includes(student) && query.matches(student) {
+			result = append(result, student)
+		}
+	})
+	if !ok {
+		return
+	}
+	sortStudents(result)
+	result = query.paginate(result)
+
+	rows := make([][]interface{}, 0, len(result)+1)
+	header := make([]interface{}, len(exportHeader))
+	for i, title := range exportHeader {
+		header[i] = title
+	}
+	rows = append(rows, header)
+	for _, student := range result {
+		rows = append(rows, studentRow(student))
+	}
+
+	// Build the whole file first, so a failure can still be answered with 500
+	var buf bytes.Buffer
+	if err := writeXLSX(&buf, "Students", rows); err != nil {
+		ErrorLogger.Printf("Failed to build XLSX export: %v", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Internal server error")
+		return
+	}
+
+	InfoLogger.Printf("Exported %d students as XLSX", len(result))
+	w.Header().Set("Content-Type", xlsxContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="students.xlsx"`)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// Fixed parts of a single-sheet workbook
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+)
+
+// writeXLSX writes a minimal Office Open XML workbook with one sheet holding
+// rows. The package is small enough to assemble with archive/zip rather than
+// pulling in a spreadsheet library. Strings are stored inline and ints as
+// numbers.
+func writeXLSX(out io.Writer, sheetName string, rows [][]interface{}) error {
+	zw := zip.NewWriter(out)
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, xmlEscape(sheetName))},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	var sheet strings.Builder
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, row := range rows {
+		fmt.Fprintf(&sheet, `<row r="%d">`, i+1)
+		for j, value := range row {
+			ref := xlsxColumn(j) + strconv.Itoa(i+1)
+			switch v := value.(type) {
+			case int:
+				fmt.Fprintf(&sheet, `<c r="%s"><v>%d</v></c>`, ref, v)
+			default:
+				fmt.Fprintf(&sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, xmlEscape(fmt.Sprint(v)))
+			}
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+	if _, err := io.WriteString(f, sheet.String()); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// xlsxColumn returns the spreadsheet column letters for a zero-based index
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// xmlEscape escapes s as XML character data, replacing characters XML
+// can't represent
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}